@@ -2,13 +2,25 @@ package main
 
 import (
 	"github.com/VJftw/please-terraform/internal/cmd"
+	"github.com/VJftw/please-terraform/pkg/affected"
+	"github.com/VJftw/please-terraform/pkg/generate"
+	"github.com/VJftw/please-terraform/pkg/lock"
+	"github.com/VJftw/please-terraform/pkg/migrate"
 	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/VJftw/please-terraform/pkg/orchestrate"
+	"github.com/VJftw/please-terraform/pkg/policy"
 	"github.com/VJftw/please-terraform/pkg/root"
 )
 
 type opts struct {
-	Module *module.Command `command:"module"`
-	Root   *root.Command   `command:"root"`
+	Module   *module.Command      `command:"module"`
+	Root     *root.Command        `command:"root"`
+	Run      *orchestrate.Command `command:"run"`
+	Affected *affected.Command    `command:"affected"`
+	Policy   *policy.Command      `command:"policy"`
+	Gen      *generate.Command    `command:"gen"`
+	Migrate  *migrate.Command     `command:"migrate"`
+	Lock     *lock.Command        `command:"lock"`
 }
 
 func main() {
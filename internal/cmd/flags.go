@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 	"path"
 
 	"github.com/VJftw/please-terraform/internal/logging"
 	"github.com/jessevdk/go-flags"
+	"github.com/rs/zerolog"
 )
 
 // MustParseFlags parses the given application options from command line arguments.
@@ -20,7 +22,9 @@ func MustParseFlags(opts interface{}) {
 	flagParser.AddGroup("logging options", "logging options", loggingOpts)
 
 	flagParser.CommandHandler = func(cmd flags.Commander, args []string) error {
-		configureLoggingFromOpts(loggingOpts)
+		if err := ConfigureLogging(loggingOpts); err != nil {
+			return err
+		}
 		return cmd.Execute(args)
 	}
 
@@ -31,17 +35,42 @@ func MustParseFlags(opts interface{}) {
 		if flagsErr, ok := err.(*flags.Error); ok {
 			handleFlagsErr(flagsErr)
 		}
-		logging.Logger.Fatal().Err(err).Msg("encountered error")
+		handleExitCodeErr(err)
+		logging.Logger.WithLevel(zerolog.FatalLevel).Err(err).Msg("encountered error")
+		exit(1)
 	}
 
 	if len(args) > 0 {
-		logging.Logger.Fatal().Strs("extra-args", args).Msg("found unexpected extra arguments")
+		logging.Logger.WithLevel(zerolog.FatalLevel).Strs("extra-args", args).Msg("found unexpected extra arguments")
+		exit(1)
 	}
 
+	CloseLogFile()
+}
+
+// exit closes the log file and exits the application with the given exit
+// code.
+func exit(code int) {
+	CloseLogFile()
+	os.Exit(code)
 }
 
 func handleFlagsErr(err *flags.Error) {
 	if err.Type == flags.ErrHelp {
-		os.Exit(0)
+		exit(0)
+	}
+}
+
+// exitCoder is implemented by errors which should cause the application to
+// exit with a specific exit code, e.g. `*exec.ExitError`.
+type exitCoder interface {
+	ExitCode() int
+}
+
+func handleExitCodeErr(err error) {
+	var exitErr exitCoder
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		logging.Logger.Error().Err(err).Int("exit_code", exitErr.ExitCode()).Msg("exiting")
+		exit(exitErr.ExitCode())
 	}
 }
@@ -1,16 +1,40 @@
 package cmd
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
 	log "github.com/VJftw/please-terraform/internal/logging"
 	"github.com/rs/zerolog"
 )
 
+// correlationIDEnv is the environment variable which carries the correlation
+// ID to nested invocations, e.g. workflows run by `please_terraform run`.
+const correlationIDEnv = "PLEASE_TERRAFORM_CORRELATION_ID"
+
 // LoggingOpts represents the available logging options for command line tools.
 type LoggingOpts struct {
-	Verbose []bool `short:"v" long:"verbose" description:"Show verbose debug information" env:"PLEASE_TERRAFORM_VERBOSE" env-delim:";"`
+	Verbose       []bool `short:"v" long:"verbose" description:"Show verbose debug information" env:"PLEASE_TERRAFORM_VERBOSE" env-delim:";"`
+	LogFormat     string `long:"log_format" default:"console" choice:"console" choice:"json" description:"The format to write logs in." env:"PLEASE_TERRAFORM_LOG_FORMAT"`
+	LogFile       string `long:"log_file" description:"The file to append logs to instead of stderr." env:"PLEASE_TERRAFORM_LOG_FILE"`
+	CorrelationID string `long:"correlation_id" description:"The ID to add to every log line. Generated if not set." env:"PLEASE_TERRAFORM_CORRELATION_ID"`
 }
 
-func configureLoggingFromOpts(opts *LoggingOpts) {
+// logFile is the log file opened for `--log_file`, which is closed by
+// CloseLogFile before the application exits.
+var logFile *os.File
+
+// logConfig is the configuration of the default Logger, which CloseLogFile
+// reconfigures it from to write to stderr.
+var logConfig *log.Config
+
+// ConfigureLogging configures the default Logger from the given options,
+// opening the log file if one is given. A correlation ID is generated if none
+// is given and is shared with nested invocations through the environment.
+func ConfigureLogging(opts *LoggingOpts) error {
 	defaultLevel := zerolog.ErrorLevel
 
 	loggingVerbosity := len(opts.Verbose)
@@ -24,5 +48,60 @@ func configureLoggingFromOpts(opts *LoggingOpts) {
 	newLevel := zerolog.Level(newLevelInt)
 
 	zerolog.SetGlobalLevel(newLevel)
-	log.Logger = log.Logger.Level(newLevel)
+
+	var out io.Writer = os.Stderr
+	if opts.LogFile != "" {
+		f, err := os.OpenFile(opts.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open log file '%s': %w", opts.LogFile, err)
+		}
+		logFile = f
+		out = f
+	}
+
+	if opts.CorrelationID == "" {
+		opts.CorrelationID = newCorrelationID()
+	}
+	// Share the correlation ID with any nested please_terraform invocations.
+	if err := os.Setenv(correlationIDEnv, opts.CorrelationID); err != nil {
+		return fmt.Errorf("could not set %s: %w", correlationIDEnv, err)
+	}
+
+	logConfig = &log.Config{
+		Format:        log.Format(opts.LogFormat),
+		Out:           out,
+		Level:         newLevel,
+		CorrelationID: opts.CorrelationID,
+	}
+	log.Configure(logConfig)
+
+	return nil
+}
+
+// CloseLogFile syncs and closes the log file, if any. Logs are written to
+// stderr in the same format afterwards.
+func CloseLogFile() {
+	if logFile == nil {
+		return
+	}
+
+	stderrConfig := *logConfig
+	stderrConfig.Out = os.Stderr
+	log.Configure(&stderrConfig)
+	if err := logFile.Sync(); err != nil {
+		log.Logger.Warn().Err(err).Msg("could not sync log file")
+	}
+	if err := logFile.Close(); err != nil {
+		log.Logger.Warn().Err(err).Msg("could not close log file")
+	}
+	logFile = nil
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
 }
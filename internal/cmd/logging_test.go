@@ -0,0 +1,143 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VJftw/please-terraform/internal/cmd"
+	"github.com/VJftw/please-terraform/internal/logging"
+	"github.com/jessevdk/go-flags"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingOptsPrecedence(t *testing.T) {
+	var tests = []struct {
+		description string
+		env         map[string]string
+		args        []string
+		expected    *cmd.LoggingOpts
+	}{
+		{
+			"defaults",
+			map[string]string{},
+			[]string{},
+			&cmd.LoggingOpts{LogFormat: "console"},
+		},
+		{
+			"env",
+			map[string]string{
+				"PLEASE_TERRAFORM_LOG_FORMAT":     "json",
+				"PLEASE_TERRAFORM_LOG_FILE":       "env.log",
+				"PLEASE_TERRAFORM_CORRELATION_ID": "env",
+			},
+			[]string{},
+			&cmd.LoggingOpts{LogFormat: "json", LogFile: "env.log", CorrelationID: "env"},
+		},
+		{
+			"flags override env",
+			map[string]string{
+				"PLEASE_TERRAFORM_LOG_FORMAT":     "json",
+				"PLEASE_TERRAFORM_LOG_FILE":       "env.log",
+				"PLEASE_TERRAFORM_CORRELATION_ID": "env",
+			},
+			[]string{"--log_format=console", "--log_file=flag.log", "--correlation_id=flag"},
+			&cmd.LoggingOpts{LogFormat: "console", LogFile: "flag.log", CorrelationID: "flag"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			for _, name := range []string{"PLEASE_TERRAFORM_LOG_FORMAT", "PLEASE_TERRAFORM_LOG_FILE", "PLEASE_TERRAFORM_CORRELATION_ID"} {
+				t.Setenv(name, tt.env[name])
+				if _, ok := tt.env[name]; !ok {
+					require.NoError(t, os.Unsetenv(name))
+				}
+			}
+
+			opts := &cmd.LoggingOpts{}
+			_, err := flags.NewParser(opts, flags.None).ParseArgs(tt.args)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, opts)
+		})
+	}
+}
+
+// readLogLines returns the JSON log lines of the given log file.
+func readLogLines(t *testing.T, logFile string) []map[string]interface{} {
+	data, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+
+	lines := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal([]byte(line), &fields))
+		lines = append(lines, fields)
+	}
+
+	return lines
+}
+
+func TestConfigureLogging(t *testing.T) {
+	logger, level := logging.Logger, zerolog.GlobalLevel()
+	t.Cleanup(func() {
+		logging.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	})
+	t.Setenv("PLEASE_TERRAFORM_CORRELATION_ID", "")
+
+	t.Run("log file", func(t *testing.T) {
+		logFile := filepath.Join(t.TempDir(), "please_terraform.log")
+		require.NoError(t, cmd.ConfigureLogging(&cmd.LoggingOpts{
+			Verbose:       []bool{true, true, true},
+			LogFormat:     "json",
+			LogFile:       logFile,
+			CorrelationID: "0123456789abcdef",
+		}))
+		assert.Equal(t, "0123456789abcdef", os.Getenv("PLEASE_TERRAFORM_CORRELATION_ID"))
+
+		logging.Logger.Info().Msg("planning")
+		cmd.CloseLogFile()
+
+		lines := readLogLines(t, logFile)
+		require.Len(t, lines, 1)
+		assert.Equal(t, "planning", lines[0]["message"])
+		assert.Equal(t, "0123456789abcdef", lines[0]["correlation_id"])
+	})
+
+	t.Run("generated correlation ID", func(t *testing.T) {
+		opts := &cmd.LoggingOpts{LogFormat: "json", LogFile: filepath.Join(t.TempDir(), "please_terraform.log")}
+		require.NoError(t, cmd.ConfigureLogging(opts))
+		cmd.CloseLogFile()
+
+		assert.Len(t, opts.CorrelationID, 16)
+		assert.Equal(t, opts.CorrelationID, os.Getenv("PLEASE_TERRAFORM_CORRELATION_ID"))
+	})
+
+	t.Run("stderr in the same format after closing the log file", func(t *testing.T) {
+		stderr := os.Stderr
+		t.Cleanup(func() { os.Stderr = stderr })
+		fakeStderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+		require.NoError(t, err)
+		defer fakeStderr.Close()
+		os.Stderr = fakeStderr
+
+		require.NoError(t, cmd.ConfigureLogging(&cmd.LoggingOpts{
+			Verbose:       []bool{true},
+			LogFormat:     "console",
+			LogFile:       filepath.Join(t.TempDir(), "please_terraform.log"),
+			CorrelationID: "0123456789abcdef",
+		}))
+		cmd.CloseLogFile()
+		logging.Logger.Error().Msg("exiting")
+
+		out, err := os.ReadFile(fakeStderr.Name())
+		require.NoError(t, err)
+		assert.NotContains(t, string(out), "{")
+		assert.Contains(t, string(out), "exiting")
+	})
+}
@@ -1,15 +1,56 @@
 package logging
 
 import (
+	"io"
 	"os"
 
 	"github.com/rs/zerolog"
 )
 
-// Logger is the default Logger.
+// Logger is the default Logger. Packages should refer to it by pointer, e.g.
+// `var log = &logging.Logger`, so that they use the Logger configured by
+// Configure rather than the one which existed when they were initialised.
 var Logger = NewLogger()
 
 // NewLogger returns a new logger.
 func NewLogger() zerolog.Logger {
 	return zerolog.New(os.Stderr).With().Timestamp().Logger().Output(zerolog.ConsoleWriter{Out: os.Stderr})
 }
+
+// Format represents the format that logs are written in.
+type Format string
+
+const (
+	// FormatConsole writes human-readable logs.
+	FormatConsole Format = "console"
+	// FormatJSON writes a JSON object per log line.
+	FormatJSON Format = "json"
+)
+
+// Config represents how the default Logger should be configured.
+type Config struct {
+	Format        Format
+	Out           io.Writer
+	Level         zerolog.Level
+	CorrelationID string
+}
+
+// Configure replaces the default Logger with one built from the given
+// Config.
+func Configure(cfg *Config) {
+	out := cfg.Out
+	if cfg.Format != FormatJSON {
+		out = zerolog.ConsoleWriter{
+			Out: out,
+			// Only colour logs written to the terminal, not to log files.
+			NoColor: out != os.Stderr,
+		}
+	}
+
+	ctx := zerolog.New(out).Level(cfg.Level).With().Timestamp()
+	if cfg.CorrelationID != "" {
+		ctx = ctx.Str("correlation_id", cfg.CorrelationID)
+	}
+
+	Logger = ctx.Logger()
+}
@@ -0,0 +1,53 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/VJftw/please-terraform/internal/logging"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigure(t *testing.T) {
+	logger := logging.Logger
+	t.Cleanup(func() { logging.Logger = logger })
+
+	t.Run("json", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logging.Configure(&logging.Config{
+			Format:        logging.FormatJSON,
+			Out:           out,
+			Level:         zerolog.InfoLevel,
+			CorrelationID: "0123456789abcdef",
+		})
+
+		logging.Logger.Debug().Msg("not logged")
+		logging.Logger.Info().Str("root", "//infra:network").Msg("planning")
+
+		line := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+		assert.Equal(t, "info", line["level"])
+		assert.Equal(t, "planning", line["message"])
+		assert.Equal(t, "//infra:network", line["root"])
+		assert.Equal(t, "0123456789abcdef", line["correlation_id"])
+		assert.Contains(t, line, "time")
+	})
+
+	t.Run("console", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		logging.Configure(&logging.Config{
+			Format:        logging.FormatConsole,
+			Out:           out,
+			Level:         zerolog.InfoLevel,
+			CorrelationID: "0123456789abcdef",
+		})
+
+		logging.Logger.Info().Msg("planning")
+
+		assert.NotContains(t, out.String(), "{")
+		assert.Contains(t, out.String(), "INF planning correlation_id=0123456789abcdef")
+	})
+}
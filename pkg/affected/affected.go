@@ -0,0 +1,191 @@
+package affected
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/root"
+)
+
+// Index represents the metadata of every built Terraform module and root.
+type Index struct {
+	Modules map[string]*module.Metadata
+	Roots   map[string]*root.Metadata
+
+	// aliases maps every alias of a module to the module's target.
+	aliases map[string]string
+}
+
+// LoadIndex loads the metadata of every Terraform module and root built
+// under the given directory, e.g. `plz-out/gen`.
+func LoadIndex(dir string, moduleMetadataFile string, rootMetadataFile string) (*Index, error) {
+	idx := &Index{
+		Modules: map[string]*module.Metadata{},
+		Roots:   map[string]*root.Metadata{},
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		// Colocated modules are copies of modules which are built elsewhere.
+		if d.Name() == ".modules" {
+			return filepath.SkipDir
+		}
+
+		moduleMeta, err := module.Load(filepath.Join(p, moduleMetadataFile))
+		if err == nil {
+			idx.Modules[moduleMeta.Target] = moduleMeta
+			return filepath.SkipDir
+		}
+
+		rootMeta, err := root.LoadMetadata(filepath.Join(p, rootMetadataFile))
+		if err == nil {
+			idx.Roots[rootMeta.Target] = rootMeta
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not walk '%s': %w", dir, err)
+	}
+
+	idx.indexAliases()
+	return idx, nil
+}
+
+func (idx *Index) indexAliases() {
+	idx.aliases = map[string]string{}
+	for target, m := range idx.Modules {
+		idx.aliases[target] = target
+		for _, alias := range m.Aliases {
+			idx.aliases[alias] = target
+		}
+	}
+}
+
+// resolve returns the module target for the given target or alias.
+func (idx *Index) resolve(ref string) string {
+	if idx.aliases == nil {
+		idx.indexAliases()
+	}
+	if target, ok := idx.aliases[ref]; ok {
+		return target
+	}
+
+	return ref
+}
+
+// AffectedRoots returns the sorted targets of the roots whose output would
+// change given the changed files, relative to the repository root. A module
+// or root is affected when one of its sources, one of its var files or its
+// BUILD file changes, or when a module it uses is affected.
+func (idx *Index) AffectedRoots(changedFiles []string, buildFileNames []string) []string {
+	changed := map[string]struct{}{}
+	changedPkgs := map[string]struct{}{}
+	for _, file := range changedFiles {
+		file = path.Clean(filepath.ToSlash(file))
+		changed[file] = struct{}{}
+		for _, buildFileName := range buildFileNames {
+			if path.Base(file) == buildFileName {
+				pkg := path.Dir(file)
+				if pkg == "." {
+					pkg = ""
+				}
+				changedPkgs[pkg] = struct{}{}
+			}
+		}
+	}
+
+	isDirectlyAffected := func(target string, files ...[]string) bool {
+		if l, err := please.ParseLabel(target); err == nil {
+			if _, ok := changedPkgs[l.Pkg]; ok {
+				return true
+			}
+		}
+		for _, fileList := range files {
+			for _, file := range fileList {
+				if _, ok := changed[path.Clean(filepath.ToSlash(file))]; ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// memoise module results as modules are commonly shared between roots.
+	affectedModules := map[string]bool{}
+	var isModuleAffected func(target string, visiting map[string]bool) bool
+	isModuleAffected = func(target string, visiting map[string]bool) bool {
+		target = idx.resolve(target)
+		if affected, ok := affectedModules[target]; ok {
+			return affected
+		}
+		m, ok := idx.Modules[target]
+		if !ok {
+			log.Warn().Str("module", target).Msg("no metadata found for module, assuming it is affected")
+			return true
+		}
+		if visiting[target] {
+			return false
+		}
+		visiting[target] = true
+
+		affected := isDirectlyAffected(target, m.Srcs)
+		for _, dep := range m.Deps {
+			if affected {
+				break
+			}
+			affected = isModuleAffected(dep, visiting)
+		}
+
+		affectedModules[target] = affected
+		return affected
+	}
+
+	affectedRoots := []string{}
+	for target, r := range idx.Roots {
+		files := [][]string{r.Srcs, r.VarFiles}
+		for _, varFiles := range r.WorkspaceVarFiles {
+			files = append(files, varFiles)
+		}
+		affected := isDirectlyAffected(target, files...)
+		for _, mod := range r.Modules {
+			if affected {
+				break
+			}
+			affected = isModuleAffected(mod, map[string]bool{})
+		}
+		if affected {
+			affectedRoots = append(affectedRoots, target)
+		}
+	}
+	sort.Strings(affectedRoots)
+
+	return affectedRoots
+}
+
+// WithWorkflow returns the given root targets with the given workflow suffix,
+// e.g. `//pkg:name_plan`.
+func WithWorkflow(targets []string, workflow string) []string {
+	if workflow == "" {
+		return targets
+	}
+
+	out := make([]string, 0, len(targets))
+	for _, target := range targets {
+		out = append(out, fmt.Sprintf("%s_%s", target, strings.TrimPrefix(workflow, "_")))
+	}
+
+	return out
+}
@@ -0,0 +1,143 @@
+package affected_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/affected"
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	moduleMetadataFile = ".please/terraform/module.json"
+	rootMetadataFile   = ".please/terraform/root.json"
+)
+
+func TestAffectedRoots(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		".gitignore":                  "plz-out\n",
+		"infra/app/BUILD":             "terraform_root(...)",
+		"infra/app/main.tf":           "",
+		"infra/app/prod.tfvars":       "",
+		"infra/network/BUILD":         "terraform_root(...)",
+		"infra/network/main.tf":       "",
+		"modules/vpc/BUILD":           "terraform_module(...)",
+		"modules/vpc/main.tf":         "",
+		"modules/subnet/BUILD":        "terraform_module(...)",
+		"modules/subnet/main.tf":      "",
+		"third_party/terraform/BUILD": "terraform_registry_module(...)",
+	})
+	gitCmd(t, repo, "init", "-q", "-b", "main")
+	gitCmd(t, repo, "add", "-A")
+	gitCmd(t, repo, "commit", "-q", "-m", "initial")
+
+	genDir := filepath.Join(repo, "plz-out", "gen")
+	saveModule(t, filepath.Join(genDir, "modules/subnet/subnet"), &module.Metadata{
+		Target:  "//modules/subnet:subnet",
+		Aliases: []string{"//modules/subnet:subnet", "//modules/subnet"},
+		Srcs:    []string{"modules/subnet/main.tf"},
+	})
+	saveModule(t, filepath.Join(genDir, "modules/vpc/vpc"), &module.Metadata{
+		Target:  "//modules/vpc:vpc",
+		Aliases: []string{"//modules/vpc:vpc", "//modules/vpc"},
+		Srcs:    []string{"modules/vpc/main.tf"},
+		Deps:    []string{"//modules/subnet"},
+	})
+	saveModule(t, filepath.Join(genDir, "third_party/terraform/label"), &module.Metadata{
+		Target:  "//third_party/terraform:label",
+		Aliases: []string{"//third_party/terraform:label", "cloudposse/label/null"},
+	})
+	// colocated copies of modules must not be indexed.
+	saveModule(t, filepath.Join(genDir, "infra/network/network_root/.modules/modules/vpc/vpc"), &module.Metadata{
+		Target: "//modules/vpc:vpc",
+	})
+	saveRoot(t, filepath.Join(genDir, "infra/network/network_root"), &root.Metadata{
+		Target:  "//infra/network:network",
+		Srcs:    []string{"infra/network/main.tf"},
+		Modules: []string{"//modules/vpc:vpc"},
+	})
+	saveRoot(t, filepath.Join(genDir, "infra/app/app_root"), &root.Metadata{
+		Target:   "//infra/app:app",
+		Srcs:     []string{"infra/app/main.tf"},
+		VarFiles: []string{"infra/app/prod.tfvars"},
+		Modules:  []string{"//third_party/terraform:label"},
+	})
+
+	idx, err := affected.LoadIndex(genDir, moduleMetadataFile, rootMetadataFile)
+	require.NoError(t, err)
+	assert.Len(t, idx.Modules, 3)
+	assert.Len(t, idx.Roots, 2)
+
+	var tests = []struct {
+		description string
+		changes     map[string]string
+		commit      bool
+		expected    []string
+	}{
+		{"no changes", map[string]string{}, false, []string{}},
+		{"root src", map[string]string{"infra/network/main.tf": "# changed"}, true, []string{"//infra/network:network"}},
+		{"root var file", map[string]string{"infra/app/prod.tfvars": "a = 1"}, true, []string{"//infra/app:app"}},
+		{"transitive module src", map[string]string{"modules/subnet/main.tf": "# changed"}, true, []string{"//infra/network:network"}},
+		{"registry module BUILD", map[string]string{"third_party/terraform/BUILD": "# changed"}, true, []string{"//infra/app:app"}},
+		{"uncommitted change", map[string]string{"modules/vpc/main.tf": "# changed"}, false, []string{"//infra/network:network"}},
+		{"untracked BUILD file", map[string]string{"modules/vpc/BUILD.plz": "# new"}, false, []string{"//infra/network:network"}},
+		{"unrelated", map[string]string{"README.md": "hello"}, true, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			gitCmd(t, repo, "checkout", "-q", "-B", "change", "main")
+			writeFiles(t, repo, tt.changes)
+			if tt.commit {
+				gitCmd(t, repo, "add", "-A")
+				gitCmd(t, repo, "commit", "-q", "-m", tt.description)
+			}
+
+			changed, err := affected.ChangedFiles(repo, "main")
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, idx.AffectedRoots(changed, []string{"BUILD", "BUILD.plz"}))
+
+			gitCmd(t, repo, "reset", "-q", "--hard")
+			gitCmd(t, repo, "clean", "-q", "-f")
+		})
+	}
+}
+
+func TestWithWorkflow(t *testing.T) {
+	assert.Equal(t, []string{"//a:a_plan", "//b:b_plan"}, affected.WithWorkflow([]string{"//a:a", "//b:b"}, "plan"))
+	assert.Equal(t, []string{"//a:a"}, affected.WithWorkflow([]string{"//a:a"}, ""))
+}
+
+func saveModule(t *testing.T, dir string, m *module.Metadata) {
+	require.NoError(t, m.Save(filepath.Join(dir, moduleMetadataFile)))
+}
+
+func saveRoot(t *testing.T, dir string, m *root.Metadata) {
+	require.NoError(t, m.Save(filepath.Join(dir, rootMetadataFile)))
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0750))
+		require.NoError(t, os.WriteFile(p, []byte(contents), 0644))
+	}
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
@@ -0,0 +1,61 @@
+package affected
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/VJftw/please-terraform/internal/logging"
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/root"
+)
+
+var log = &logging.Logger
+
+// Command represents the `affected` command and its flags.
+type Command struct {
+	Base           string   `long:"base" required:"true" description:"The git revision to compare the working tree against, e.g. origin/main."`
+	Workflow       string   `long:"workflow" description:"If set, prints the given workflow target of each root instead, e.g. 'plan' for '<root>_plan'."`
+	BuildFileNames []string `long:"build_file_name" default:"BUILD" default:"BUILD.plz" description:"The names of Please BUILD files."`
+
+	PleaseOpts *please.Opts
+	ModuleOpts *module.Opts
+	RootOpts   *root.Opts
+}
+
+// Execute prints the labels of the Terraform roots affected by the changes
+// since the configured base revision, one per line, so that they can be
+// piped into `plz run sequential -`.
+func (c *Command) Execute(args []string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("could not get current working directory: %w", err)
+	}
+
+	repoRoot, err := RepoRoot(cwd)
+	if err != nil {
+		return err
+	}
+
+	changedFiles, err := ChangedFiles(repoRoot, c.Base)
+	if err != nil {
+		return err
+	}
+	log.Debug().Strs("files", changedFiles).Msg("found changed files")
+
+	genDir := filepath.Join(repoRoot, c.PleaseOpts.PlzOutDir, "gen")
+	idx, err := LoadIndex(genDir, c.ModuleOpts.MetadataFile, c.RootOpts.MetadataFile)
+	if err != nil {
+		return err
+	}
+	if len(idx.Roots) == 0 {
+		log.Warn().Str("path", genDir).Msg("no built Terraform roots found, have they been built?")
+	}
+
+	for _, label := range WithWorkflow(idx.AffectedRoots(changedFiles, c.BuildFileNames), c.Workflow) {
+		fmt.Println(label)
+	}
+
+	return nil
+}
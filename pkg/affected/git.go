@@ -0,0 +1,64 @@
+package affected
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ChangedFiles returns the files, relative to the root of the given git
+// repository, which have changed in the working tree since it diverged from
+// the given base revision. Untracked files are included.
+func ChangedFiles(repoDir string, base string) ([]string, error) {
+	mergeBase, err := git(repoDir, "merge-base", base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := git(repoDir, "diff", "--name-only", "--no-renames", strings.TrimSpace(mergeBase))
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := git(repoDir, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, line := range strings.Split(diff+"\n"+untracked, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+
+	return files, nil
+}
+
+// RepoRoot returns the root of the git repository containing the given
+// directory.
+func RepoRoot(dir string) (string, error) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	log.Debug().Strs("args", args).Msg("running git")
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("could not run 'git %s': %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,63 @@
+package generate
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/VJftw/please-terraform/internal/logging"
+	"github.com/VJftw/please-terraform/pkg/please"
+)
+
+var log = &logging.Logger
+
+// Command represents the `gen` command and its flags.
+type Command struct {
+	Dir           string `long:"dir" default:"." description:"The directory to scan for Terraform configuration."`
+	Subinclude    string `long:"subinclude" default:"///terraform//build/defs:terraform" description:"The build label of the Terraform build definitions to subinclude into new BUILD files."`
+	RegistryPkg   string `long:"registry_pkg" default:"third_party/terraform/module" description:"The package to generate terraform_registry_module rules in."`
+	Toolchain     string `long:"toolchain" description:"The terraform_toolchain to set on generated terraform_root rules."`
+	BuildFileName string `long:"build_file_name" default:"BUILD" description:"The name of the BUILD files to write."`
+	Update        bool   `long:"update" description:"Update the srcs, var_files, modules and deps of rules in existing BUILD files, keeping their other attributes."`
+}
+
+// Execute generates BUILD files for the Terraform configuration in the
+// configured directory.
+func (c *Command) Execute(args []string) error {
+	dir, err := filepath.Abs(c.Dir)
+	if err != nil {
+		return fmt.Errorf("could not resolve '%s': %w", c.Dir, err)
+	}
+
+	repoRoot, err := please.FindRepoRoot(dir)
+	if err != nil {
+		return err
+	}
+
+	relDir, err := filepath.Rel(repoRoot, dir)
+	if err != nil {
+		return err
+	}
+
+	dirs, err := Scan(repoRoot, relDir)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		log.Debug().Str("pkg", d.Pkg).Str("kind", string(d.Kind)).Msg("found Terraform configuration")
+	}
+	if len(dirs) == 0 {
+		log.Warn().Str("path", dir).Msg("no Terraform configuration found")
+		return nil
+	}
+
+	g := &Generator{
+		RepoRoot:      repoRoot,
+		Subinclude:    c.Subinclude,
+		RegistryPkg:   c.RegistryPkg,
+		Toolchain:     c.Toolchain,
+		BuildFileName: c.BuildFileName,
+		Update:        c.Update,
+	}
+
+	return g.Generate(dirs)
+}
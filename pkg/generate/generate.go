@@ -0,0 +1,297 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+)
+
+// Generator generates Please BUILD files for scanned directories of Terraform
+// configuration.
+type Generator struct {
+	RepoRoot string
+	// Subinclude is the build label subincluded into new BUILD files.
+	Subinclude string
+	// RegistryPkg is the package in which `terraform_registry_module` rules
+	// are generated.
+	RegistryPkg   string
+	Toolchain     string
+	BuildFileName string
+	// Update updates the attributes managed by the generator on rules in
+	// existing BUILD files instead of skipping them.
+	Update bool
+}
+
+// registryRule represents a `terraform_registry_module` rule.
+type registryRule struct {
+	name     string
+	registry string
+	module   string
+	version  string
+}
+
+// exactVersionRegex matches versions which only allow a single version.
+var exactVersionRegex = regexp.MustCompile(`^=?\s*v?[0-9]+\.[0-9]+\.[0-9]+(?:-[0-9A-Za-z.-]+)?$`)
+
+var invalidNameCharsRegex = regexp.MustCompile(`[^0-9A-Za-z_]+`)
+
+// Generate writes a BUILD file for each of the given directories, and the
+// `terraform_registry_module` rules for the registry sources they use.
+func (g *Generator) Generate(dirs []*Dir) error {
+	dirsByPkg := map[string]*Dir{}
+	for _, d := range dirs {
+		dirsByPkg[d.Pkg] = d
+	}
+
+	registryRules := map[string]*registryRule{}
+	for _, d := range dirs {
+		deps := g.resolveModuleCalls(d, dirsByPkg, registryRules)
+
+		attrs := []*attr{
+			{"srcs", stringList(d.Srcs), false},
+		}
+		switch d.Kind {
+		case KindRoot:
+			attrs = append(attrs,
+				&attr{"var_files", stringList(d.VarFiles), false},
+				&attr{"modules", stringList(deps), false},
+			)
+			if g.Toolchain != "" {
+				attrs = append(attrs, &attr{"toolchain", &build.StringExpr{Value: g.Toolchain}, true})
+			}
+		case KindModule:
+			attrs = append(attrs,
+				&attr{"deps", stringList(deps), false},
+				&attr{"visibility", stringList([]string{"PUBLIC"}), true},
+			)
+		}
+
+		if err := g.writeRules(d.Pkg, []*rule{{string(d.Kind), d.Name(), attrs}}, g.Update); err != nil {
+			return err
+		}
+	}
+
+	if len(registryRules) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(registryRules))
+	for name := range registryRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rules := []*rule{}
+	for _, name := range names {
+		r := registryRules[name]
+		attrs := []*attr{
+			{"module", &build.StringExpr{Value: r.module}, false},
+			{"version", &build.StringExpr{Value: r.version}, false},
+			{"visibility", stringList([]string{"PUBLIC"}), true},
+		}
+		if r.registry != "" {
+			attrs = append(attrs, &attr{"registry", &build.StringExpr{Value: r.registry}, false})
+		}
+		rules = append(rules, &rule{"terraform_registry_module", r.name, attrs})
+	}
+
+	// Existing registry module rules are never updated as they are likely to
+	// have hand-written hashes, strips and licences.
+	return g.writeRules(g.RegistryPkg, rules, false)
+}
+
+// resolveModuleCalls returns the build labels of the modules called by the
+// given directory, adding any registry modules to the given registry rules.
+func (g *Generator) resolveModuleCalls(d *Dir, dirsByPkg map[string]*Dir, registryRules map[string]*registryRule) []string {
+	deps := []string{}
+	seen := map[string]struct{}{}
+	addDep := func(label string) {
+		if _, ok := seen[label]; !ok {
+			seen[label] = struct{}{}
+			deps = append(deps, label)
+		}
+	}
+
+	for _, mc := range d.ModuleCalls {
+		logger := log.With().
+			Str("pkg", d.Pkg).
+			Str("module", mc.Name).
+			Str("source", mc.Source).
+			Logger()
+
+		if tfconfig.IsPleaseSource(mc.Source) {
+			addDep(mc.Source)
+			continue
+		}
+
+		if tfconfig.IsLocalSource(mc.Source) {
+			modulePkg := path.Clean(path.Join(d.Pkg, mc.Source))
+			if modulePkg == "." {
+				modulePkg = ""
+			}
+			moduleDir, ok := dirsByPkg[modulePkg]
+			if !ok {
+				logger.Warn().Msg("local module source is not within the scanned directory, skipping")
+				continue
+			}
+			if moduleDir.Kind != KindModule {
+				logger.Warn().Msg("local module source is classified as a root, skipping")
+				continue
+			}
+			addDep(moduleDir.Label())
+			continue
+		}
+
+		source, ok := tfconfig.ParseRegistrySource(mc.Source)
+		if !ok {
+			logger.Warn().Msg("module source is not supported, skipping")
+			continue
+		}
+		if !exactVersionRegex.MatchString(mc.Version) {
+			logger.Warn().Str("version", mc.Version).Msg("registry module does not have an exact version, skipping")
+			continue
+		}
+		if source.Subdir != "" {
+			logger.Warn().Msg("registry module source has a subdirectory which may need to be updated by hand")
+		}
+
+		version := strings.TrimSpace(strings.TrimPrefix(mc.Version, "="))
+		name := invalidNameCharsRegex.ReplaceAllString(
+			strings.Join([]string{source.Namespace, source.Name, source.Provider, version}, "_"),
+			"_",
+		)
+		r := &registryRule{
+			name:    name,
+			module:  fmt.Sprintf("%s/%s/%s", source.Namespace, source.Name, source.Provider),
+			version: version,
+		}
+		if source.Host != "" {
+			r.registry = "https://" + source.Host
+		}
+		registryRules[name] = r
+
+		addDep(fmt.Sprintf("//%s:%s", g.RegistryPkg, name))
+	}
+
+	sort.Strings(deps)
+
+	return deps
+}
+
+// rule represents a rule to generate with the attributes managed by the
+// generator.
+type rule struct {
+	kind  string
+	name  string
+	attrs []*attr
+}
+
+type attr struct {
+	key   string
+	value build.Expr
+	// createOnly is whether the attribute is only set on new rules, so that
+	// hand-edited values of existing rules are kept.
+	createOnly bool
+}
+
+// writeRules adds the given rules to the BUILD file of the given package,
+// creating it if it does not exist. Rules which already exist are only
+// updated if update is set, in which case only the managed attributes which
+// are not createOnly are replaced. Empty lists are not set on new rules and do
+// not replace the lists of existing rules, as the scan may not have resolved
+// hand-written values.
+func (g *Generator) writeRules(pkg string, rules []*rule, update bool) error {
+	buildFilePath := filepath.Join(g.RepoRoot, pkg, g.BuildFileName)
+	logger := log.With().Str("path", buildFilePath).Logger()
+
+	f, exists, err := g.loadBuildFile(buildFilePath)
+	if err != nil {
+		logger.Warn().Err(err).Msg("could not parse existing BUILD file, skipping")
+		return nil
+	}
+
+	changed := false
+	for _, r := range rules {
+		existing := f.RuleNamed(r.name)
+		if existing != nil && !update {
+			logger.Warn().Str("name", r.name).Msg("rule already exists, skipping")
+			continue
+		}
+		created := existing == nil
+		if created {
+			existing = build.NewRule(&build.CallExpr{X: &build.Ident{Name: r.kind}})
+			existing.SetAttr("name", &build.StringExpr{Value: r.name})
+			f.Stmt = append(f.Stmt, existing.Call)
+		}
+
+		for _, a := range r.attrs {
+			if a.createOnly && !created {
+				continue
+			}
+			if list, ok := a.value.(*build.ListExpr); ok && len(list.List) == 0 {
+				continue
+			}
+			existing.SetAttr(a.key, a.value)
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if !exists {
+		subinclude := &build.CallExpr{
+			X:    &build.Ident{Name: "subinclude"},
+			List: []build.Expr{&build.StringExpr{Value: g.Subinclude}},
+		}
+		f.Stmt = append([]build.Expr{subinclude}, f.Stmt...)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(buildFilePath), 0755); err != nil {
+		return fmt.Errorf("could not create directory for '%s': %w", buildFilePath, err)
+	}
+	if err := os.WriteFile(buildFilePath, build.Format(f), 0644); err != nil {
+		return fmt.Errorf("could not write '%s': %w", buildFilePath, err)
+	}
+	logger.Info().Msg("wrote BUILD file")
+
+	return nil
+}
+
+// loadBuildFile parses the given BUILD file, returning an empty BUILD file
+// if it does not exist.
+func (g *Generator) loadBuildFile(buildFilePath string) (*build.File, bool, error) {
+	data, err := os.ReadFile(buildFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return &build.File{Path: buildFilePath, Type: build.TypeBuild}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	f, err := build.ParseBuild(buildFilePath, data)
+	if err != nil {
+		return nil, true, err
+	}
+
+	return f, true, nil
+}
+
+func stringList(values []string) *build.ListExpr {
+	list := &build.ListExpr{List: []build.Expr{}}
+	for _, v := range values {
+		list.List = append(list.List, &build.StringExpr{Value: v})
+	}
+
+	return list
+}
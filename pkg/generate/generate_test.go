@@ -0,0 +1,206 @@
+package generate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/generate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		".plzconfig": "",
+		"infra/app/main.tf": `
+terraform {
+  backend "s3" {
+    bucket = "state"
+  }
+}
+
+module "vpc" {
+  source = "../../modules/vpc"
+}
+
+module "label" {
+  source  = "cloudposse/label/null"
+  version = "0.25.0"
+}
+
+module "unpinned" {
+  source  = "cloudposse/label/null"
+  version = "~> 0.25"
+}
+`,
+		"infra/app/variables.tf":   `variable "name" {}`,
+		"infra/app/prod.tfvars":    `name = "prod"`,
+		"modules/vpc/main.tf":      `module "subnet" { source = "../subnet" }`,
+		"modules/subnet/main.tf":   `resource "null_resource" "subnet" {}`,
+		"modules/subnet/README.md": "",
+	})
+
+	dirs, err := generate.Scan(repo, ".")
+	require.NoError(t, err)
+	require.Len(t, dirs, 3)
+
+	g := &generate.Generator{
+		RepoRoot:      repo,
+		Subinclude:    "///terraform//build/defs:terraform",
+		RegistryPkg:   "third_party/terraform/module",
+		Toolchain:     "//third_party/terraform:1.5",
+		BuildFileName: "BUILD",
+	}
+	require.NoError(t, g.Generate(dirs))
+
+	assertFile(t, filepath.Join(repo, "infra/app/BUILD"), `subinclude("///terraform//build/defs:terraform")
+
+terraform_root(
+    name = "app",
+    srcs = [
+        "main.tf",
+        "variables.tf",
+    ],
+    modules = [
+        "//modules/vpc:vpc",
+        "//third_party/terraform/module:cloudposse_label_null_0_25_0",
+    ],
+    toolchain = "//third_party/terraform:1.5",
+    var_files = ["prod.tfvars"],
+)
+`)
+	assertFile(t, filepath.Join(repo, "modules/vpc/BUILD"), `subinclude("///terraform//build/defs:terraform")
+
+terraform_module(
+    name = "vpc",
+    srcs = ["main.tf"],
+    visibility = ["PUBLIC"],
+    deps = ["//modules/subnet"],
+)
+`)
+	assertFile(t, filepath.Join(repo, "modules/subnet/BUILD"), `subinclude("///terraform//build/defs:terraform")
+
+terraform_module(
+    name = "subnet",
+    srcs = ["main.tf"],
+    visibility = ["PUBLIC"],
+)
+`)
+	assertFile(t, filepath.Join(repo, "third_party/terraform/module/BUILD"), `subinclude("///terraform//build/defs:terraform")
+
+terraform_registry_module(
+    name = "cloudposse_label_null_0_25_0",
+    module = "cloudposse/label/null",
+    version = "0.25.0",
+    visibility = ["PUBLIC"],
+)
+`)
+}
+
+func TestGenerateUpdate(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		".plzconfig":          "",
+		"modules/vpc/main.tf": "",
+		"modules/vpc/vars.tf": "",
+		"modules/vpc/BUILD": `subinclude("//build/defs:terraform")
+
+terraform_module(
+    name = "vpc",
+    srcs = ["main.tf"],
+    labels = ["team:network"],
+    visibility = ["//infra/..."],
+    deps = ["//modules/subnets"],
+)
+`,
+	})
+
+	dirs, err := generate.Scan(repo, "modules")
+	require.NoError(t, err)
+
+	g := &generate.Generator{
+		RepoRoot:      repo,
+		Subinclude:    "///terraform//build/defs:terraform",
+		RegistryPkg:   "third_party/terraform/module",
+		BuildFileName: "BUILD",
+	}
+
+	// existing rules are left alone without update.
+	require.NoError(t, g.Generate(dirs))
+	contents, err := os.ReadFile(filepath.Join(repo, "modules/vpc/BUILD"))
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), `srcs = ["main.tf"],`)
+
+	g.Update = true
+	require.NoError(t, g.Generate(dirs))
+	assertFile(t, filepath.Join(repo, "modules/vpc/BUILD"), `subinclude("//build/defs:terraform")
+
+terraform_module(
+    name = "vpc",
+    srcs = [
+        "main.tf",
+        "vars.tf",
+    ],
+    labels = ["team:network"],
+    visibility = ["//infra/..."],
+    deps = ["//modules/subnets"],
+)
+`)
+}
+
+func TestGenerateRepoRoot(t *testing.T) {
+	repo := filepath.Join(t.TempDir(), "my-infra")
+	writeFiles(t, repo, map[string]string{
+		".plzconfig": "",
+		"main.tf": `
+provider "aws" {}
+
+module "vpc" {
+  source = "./modules/vpc"
+}
+`,
+		"modules/vpc/main.tf": `resource "null_resource" "vpc" {}`,
+	})
+
+	dirs, err := generate.Scan(repo, ".")
+	require.NoError(t, err)
+	require.Len(t, dirs, 2)
+	assert.Equal(t, "", dirs[0].Pkg)
+	assert.Equal(t, "my_infra", dirs[0].Name())
+	assert.Equal(t, "//:my_infra", dirs[0].Label())
+
+	g := &generate.Generator{
+		RepoRoot:      repo,
+		Subinclude:    "///terraform//build/defs:terraform",
+		RegistryPkg:   "third_party/terraform/module",
+		BuildFileName: "BUILD",
+	}
+	require.NoError(t, g.Generate(dirs))
+
+	assertFile(t, filepath.Join(repo, "BUILD"), `subinclude("///terraform//build/defs:terraform")
+
+terraform_root(
+    name = "my_infra",
+    srcs = ["main.tf"],
+    modules = ["//modules/vpc:vpc"],
+)
+`)
+}
+
+func assertFile(t *testing.T, path string, expected string) {
+	t.Helper()
+	contents, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(contents))
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+}
@@ -0,0 +1,142 @@
+package generate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+)
+
+// Kind is the kind of Please rule a directory of Terraform configuration is
+// generated as.
+type Kind string
+
+const (
+	// KindRoot is a directory which is generated as a `terraform_root`.
+	KindRoot Kind = "terraform_root"
+	// KindModule is a directory which is generated as a `terraform_module`.
+	KindModule Kind = "terraform_module"
+)
+
+// Dir represents a directory of Terraform configuration.
+type Dir struct {
+	// Pkg is the directory relative to the repository root, which is empty
+	// for the repository root itself.
+	Pkg         string
+	Kind        Kind
+	Srcs        []string
+	VarFiles    []string
+	ModuleCalls []*tfconfig.ModuleCall
+
+	name string
+}
+
+// Name returns the name of the rule generated for the directory: the name of
+// the directory, or of the repository for the repository root.
+func (d *Dir) Name() string {
+	return d.name
+}
+
+// Label returns the Please build label of the rule generated for the directory.
+func (d *Dir) Label() string {
+	return fmt.Sprintf("//%s:%s", d.Pkg, d.Name())
+}
+
+// Scan walks the given directory, relative to the repository root, and
+// returns each directory containing Terraform configuration. A directory is
+// classified as a root if it configures a backend or a provider, or has
+// variable definition files, otherwise it is a module.
+func Scan(repoRoot string, dir string) ([]*Dir, error) {
+	dirs := []*Dir{}
+	err := filepath.WalkDir(filepath.Join(repoRoot, dir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if _, ok := please.SkipDirs[d.Name()]; ok {
+			return filepath.SkipDir
+		}
+
+		configFiles, err := tfconfig.ConfigFiles(path)
+		if err != nil {
+			return err
+		}
+		if len(configFiles) == 0 {
+			return nil
+		}
+
+		pkg, err := filepath.Rel(repoRoot, path)
+		if err != nil {
+			return err
+		}
+		pkg = filepath.ToSlash(pkg)
+		name := filepath.Base(pkg)
+		if pkg == "." {
+			absRepoRoot, err := filepath.Abs(repoRoot)
+			if err != nil {
+				return err
+			}
+			pkg = ""
+			name = invalidNameCharsRegex.ReplaceAllString(filepath.Base(absRepoRoot), "_")
+		}
+
+		scanned, err := scanDir(path, pkg)
+		if err != nil {
+			return err
+		}
+		scanned.name = name
+		dirs = append(dirs, scanned)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not scan '%s': %w", dir, err)
+	}
+
+	return dirs, nil
+}
+
+func scanDir(path string, pkg string) (*Dir, error) {
+	d := &Dir{Pkg: pkg, Kind: KindModule, Srcs: []string{}, VarFiles: []string{}}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch name := entry.Name(); {
+		case tfconfig.IsConfigFile(name):
+			d.Srcs = append(d.Srcs, name)
+		case strings.HasSuffix(name, ".tfvars"), strings.HasSuffix(name, ".tfvars.json"):
+			d.VarFiles = append(d.VarFiles, name)
+		}
+	}
+	sort.Strings(d.Srcs)
+	sort.Strings(d.VarFiles)
+
+	m, err := tfconfig.LoadDir(path)
+	if err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("could not fully parse Terraform configuration")
+	}
+	if m != nil {
+		d.ModuleCalls = m.ModuleCalls
+		if m.Backend != nil || len(m.ProviderConfigs) > 0 {
+			d.Kind = KindRoot
+		}
+	}
+	if len(d.VarFiles) > 0 {
+		d.Kind = KindRoot
+	}
+
+	return d, nil
+}
@@ -0,0 +1,165 @@
+// Package licence detects the licences of Terraform modules from their
+// licence files, identifying them by their SPDX identifiers.
+package licence
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/VJftw/please-terraform/internal/logging"
+)
+
+var log = &logging.Logger
+
+// NoAssertion is the SPDX value for a licence which could not be determined.
+const NoAssertion = "NOASSERTION"
+
+// fileRegex matches the names of files which commonly contain licences.
+var fileRegex = regexp.MustCompile(`(?i)^(licen[cs]e|copying|unlicen[cs]e)([.-].*)?$`)
+
+var spdxIdentifierRegex = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+)`)
+
+// nonWordRegex matches everything that is not significant when comparing
+// licence texts, e.g. comment characters and line wrapping.
+var nonWordRegex = regexp.MustCompile(`[^a-z0-9.,/()-]+`)
+
+// matcher identifies a licence by phrases which must all be in its text.
+type matcher struct {
+	id      string
+	phrases []string
+}
+
+// matchers are ordered from most to least specific, e.g. the LGPL contains
+// the phrases of the GPL.
+var matchers = []*matcher{
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MIT", []string{"permission is hereby granted, free of charge", "the above copyright notice and this permission notice shall be included"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose with or without fee is hereby granted"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}},
+}
+
+// Detect returns the sorted SPDX identifiers of the licences in the licence
+// files at the top level of the given directory.
+func Detect(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read directory '%s': %w", dir, err)
+	}
+
+	found := map[string]struct{}{}
+	for _, entry := range entries {
+		if entry.IsDir() || !fileRegex.MatchString(entry.Name()) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read licence file '%s': %w", path, err)
+		}
+
+		id, ok := Identify(string(contents))
+		if !ok {
+			log.Warn().Str("path", path).Msg("could not identify licence")
+			continue
+		}
+		log.Debug().Str("path", path).Str("licence", id).Msg("identified licence")
+		found[id] = struct{}{}
+	}
+
+	ids := make([]string, 0, len(found))
+	for id := range found {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// Identify returns the SPDX identifier of the licence in the given text. An
+// explicit `SPDX-License-Identifier` takes precedence over the text.
+func Identify(text string) (string, bool) {
+	if matches := spdxIdentifierRegex.FindStringSubmatch(text); matches != nil {
+		return matches[1], true
+	}
+
+	normalised := nonWordRegex.ReplaceAllString(strings.ToLower(text), " ")
+	for _, m := range matchers {
+		if m.matches(normalised) {
+			return m.id, true
+		}
+	}
+
+	return "", false
+}
+
+func (m *matcher) matches(normalised string) bool {
+	for _, phrase := range m.phrases {
+		if !strings.Contains(normalised, phrase) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Policy represents the licences which modules must have.
+type Policy struct {
+	// Declared are the licences a module is declared to have. If set, every
+	// detected licence must be declared.
+	Declared []string
+	// Allowed are the only licences a module may have, if set.
+	Allowed []string
+	// Denied are the licences a module must not have.
+	Denied []string
+}
+
+// Check returns an error if the given detected licences do not satisfy the
+// policy.
+func (p *Policy) Check(detected []string) error {
+	if len(detected) == 0 {
+		if len(p.Declared) > 0 || len(p.Allowed) > 0 {
+			return fmt.Errorf("could not detect a licence, expected one of %s", strings.Join(append(p.Declared, p.Allowed...), ", "))
+		}
+		return nil
+	}
+
+	for _, id := range detected {
+		if contains(p.Denied, id) {
+			return fmt.Errorf("licence '%s' is denied", id)
+		}
+		if len(p.Allowed) > 0 && !contains(p.Allowed, id) {
+			return fmt.Errorf("licence '%s' is not allowed, allowed licences are %s", id, strings.Join(p.Allowed, ", "))
+		}
+		if len(p.Declared) > 0 && !contains(p.Declared, id) {
+			return fmt.Errorf("detected licence '%s' does not match the declared licences %s", id, strings.Join(p.Declared, ", "))
+		}
+	}
+
+	return nil
+}
+
+// contains returns whether the given SPDX identifiers contain the given
+// identifier, ignoring case as SPDX identifiers are case-insensitive.
+func contains(ids []string, id string) bool {
+	for _, i := range ids {
+		if strings.EqualFold(i, id) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package licence_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/licence"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mitLicence = `MIT License
+
+Copyright (c) 2021 Example
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction.
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+`
+
+const apacheLicence = `
+                                 Apache License
+                           Version 2.0, January 2004
+                        http://www.apache.org/licenses/
+`
+
+const bsd3Licence = `Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+ * Neither the name of the copyright holder nor the names of its contributors
+   may be used to endorse or promote products derived from this software.
+`
+
+func TestIdentify(t *testing.T) {
+	var tests = []struct {
+		description string
+		text        string
+		expected    string
+	}{
+		{"mit", mitLicence, "MIT"},
+		{"apache", apacheLicence, "Apache-2.0"},
+		{"bsd 3 clause", bsd3Licence, "BSD-3-Clause"},
+		{"lgpl before gpl", "GNU LESSER GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007", "LGPL-3.0"},
+		{"gpl", "GNU GENERAL PUBLIC LICENSE\n   Version 2, June 1991", "GPL-2.0"},
+		{"mpl", "Mozilla Public License Version 2.0\n==================================", "MPL-2.0"},
+		{"spdx identifier", "# SPDX-License-Identifier: MPL-2.0\n" + mitLicence, "MPL-2.0"},
+		{"unknown", "All rights reserved.", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			id, ok := licence.Identify(tt.text)
+			assert.Equal(t, tt.expected != "", ok)
+			assert.Equal(t, tt.expected, id)
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"LICENSE":       apacheLicence,
+		"LICENCE-MIT":   mitLicence,
+		"COPYING":       apacheLicence,
+		"main.tf":       mitLicence,
+		"docs/LICENSE":  bsd3Licence,
+		"NOTICE":        bsd3Licence,
+		"licenses.json": "{}",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+
+	ids, err := licence.Detect(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Apache-2.0", "MIT"}, ids)
+}
+
+func TestPolicyCheck(t *testing.T) {
+	var tests = []struct {
+		description string
+		policy      *licence.Policy
+		detected    []string
+		expectedErr string
+	}{
+		{"no policy", &licence.Policy{}, []string{"GPL-3.0"}, ""},
+		{"no policy and no licence", &licence.Policy{}, []string{}, ""},
+		{"declared", &licence.Policy{Declared: []string{"apache-2.0"}}, []string{"Apache-2.0"}, ""},
+		{"declared mismatch", &licence.Policy{Declared: []string{"Apache-2.0"}}, []string{"MIT"}, "detected licence 'MIT' does not match the declared licences Apache-2.0"},
+		{"declared but undetected", &licence.Policy{Declared: []string{"Apache-2.0"}}, []string{}, "could not detect a licence"},
+		{"allowed", &licence.Policy{Allowed: []string{"MIT", "Apache-2.0"}}, []string{"MIT"}, ""},
+		{"not allowed", &licence.Policy{Allowed: []string{"MIT"}}, []string{"GPL-3.0"}, "licence 'GPL-3.0' is not allowed"},
+		{"denied", &licence.Policy{Denied: []string{"GPL-3.0"}}, []string{"MIT", "GPL-3.0"}, "licence 'GPL-3.0' is denied"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			err := tt.policy.Check(tt.detected)
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
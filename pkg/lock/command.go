@@ -0,0 +1,217 @@
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/VJftw/please-terraform/internal/logging"
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/root"
+)
+
+var log = &logging.Logger
+
+// Command represents the `lock` command and available subcommands.
+type Command struct {
+	Sync  *CommandSync  `command:"sync"`
+	Check *CommandCheck `command:"check"`
+}
+
+// CommandSync represents the `lock sync` command and its flags.
+type CommandSync struct {
+	RootModule        string `long:"root_module" required:"true" description:"The built Terraform root whose virtual env has the updated lock file."`
+	VirtualEnvBaseDir string `long:"virtual_env_base_dir" default:"./plz-out/terraform/venvs" description:"The directory which the Terraform root's virtual env is in."`
+
+	PleaseOpts *please.Opts
+	RootOpts   *root.Opts
+}
+
+// Execute copies the lock file in the virtual env of the configured Terraform
+// root back to the root's source package.
+func (c *CommandSync) Execute(args []string) error {
+	repoRoot := please.MustRepoRoot(c.PleaseOpts.PlzOutDir)
+	if strings.HasPrefix(c.VirtualEnvBaseDir, "./") {
+		c.VirtualEnvBaseDir = filepath.Join(repoRoot, c.VirtualEnvBaseDir)
+	}
+
+	m, err := root.LoadMetadata(filepath.Join(c.RootModule, c.RootOpts.MetadataFile))
+	if err != nil {
+		return err
+	}
+
+	src := filepath.Join(c.VirtualEnvBaseDir, c.RootModule, root.LockFileName)
+	srcBytes, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("could not read the lock file of '%s', run 'terraform init' first: %w", m.Target, err)
+	}
+
+	dest := m.LockFile
+	if dest == "" {
+		pkg := strings.TrimPrefix(strings.SplitN(m.Target, ":", 2)[0], "//")
+		dest = filepath.Join(pkg, root.LockFileName)
+		log.Warn().Str("root", m.Target).Str("lock_file", dest).Msg("add the lock file to the root's srcs so that it is used")
+	}
+	dest = filepath.Join(repoRoot, dest)
+
+	if destBytes, err := os.ReadFile(dest); err == nil && bytes.Equal(srcBytes, destBytes) {
+		log.Info().Str("lock_file", dest).Msg("lock file is up to date")
+		return nil
+	}
+
+	if err := os.WriteFile(dest, srcBytes, 0644); err != nil {
+		return fmt.Errorf("could not write '%s': %w", dest, err)
+	}
+	log.Info().Str("lock_file", dest).Msg("updated lock file")
+
+	return nil
+}
+
+// CommandCheck represents the `lock check` command and its flags.
+type CommandCheck struct {
+	RootModule string   `long:"root_module" description:"The built Terraform root whose lock file to check."`
+	LockFile   string   `long:"lock_file" description:"The lock file to check, instead of the lock file of a built Terraform root."`
+	Platforms  []string `long:"platforms" required:"true" description:"A platform, e.g. 'linux_amd64', which the lock file must have the hashes of every provider for."`
+	Registries []string `long:"registries" description:"A '<host>=<url>' pair of the URL of the provider registry to use for a provider source host."`
+
+	HTTP *module.HTTPOpts
+}
+
+// Execute checks that the configured lock file has the hashes of every
+// provider for every configured platform.
+func (c *CommandCheck) Execute(args []string) error {
+	lockFile := c.LockFile
+	if lockFile == "" {
+		if c.RootModule == "" {
+			return fmt.Errorf("one of --root_module or --lock_file must be specified")
+		}
+		lockFile = filepath.Join(c.RootModule, root.LockFileName)
+	}
+	if _, err := os.Stat(lockFile); err != nil {
+		return fmt.Errorf("could not find lock file '%s', add '%s' to the root's srcs: %w", lockFile, root.LockFileName, err)
+	}
+
+	l, err := Load(lockFile)
+	if err != nil {
+		return err
+	}
+
+	client, err := module.NewHTTPClient(c.HTTP)
+	if err != nil {
+		return err
+	}
+	registry := &Registry{Client: client, URLs: map[string]string{}}
+	for _, pair := range c.Registries {
+		host, url, found := strings.Cut(pair, "=")
+		if !found || host == "" || url == "" {
+			return fmt.Errorf("registry '%s' is not in the form '<host>=<url>'", pair)
+		}
+		registry.URLs[host] = url
+	}
+
+	missing, err := MissingHashes(l, c.Platforms, registry.Shasum)
+	if err != nil {
+		return err
+	}
+	for _, m := range missing {
+		log.Error().Str("provider", m.Provider.Source).Str("version", m.Provider.Version).Str("platform", m.Platform).Msg("lock file lacks hashes for platform")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("lock file '%s' lacks hashes for %d provider platforms, add them with 'terraform providers lock' and 'please_terraform lock sync'", lockFile, len(missing))
+	}
+
+	log.Info().Str("lock_file", lockFile).Strs("platforms", c.Platforms).Msg("lock file has hashes for all platforms")
+	return nil
+}
+
+// MissingHash represents a platform of a provider which a lock file does not
+// have the hash of.
+type MissingHash struct {
+	Provider *Provider
+	Platform string
+}
+
+// ShasumFunc returns the SHA-256 checksum of the package of the given provider
+// for the given platform.
+type ShasumFunc func(p *Provider, platform string) (string, error)
+
+// MissingHashes returns the platforms of each provider in the given lock file
+// whose package's `zh:` hash is not in the lock file. These are the hashes
+// which Terraform verifies packages downloaded from a registry against.
+func MissingHashes(l *Lock, platforms []string, shasum ShasumFunc) ([]*MissingHash, error) {
+	missing := []*MissingHash{}
+	for _, p := range l.Providers {
+		for _, platform := range platforms {
+			sum, err := shasum(p, platform)
+			if err != nil {
+				return nil, err
+			}
+			if !p.HasHash("zh:" + sum) {
+				missing = append(missing, &MissingHash{Provider: p, Platform: platform})
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+// Registry represents the provider registries which packages are downloaded
+// from.
+type Registry struct {
+	Client *http.Client
+	// URLs are the base URLs of registries keyed by their host, for registries
+	// which are not served over HTTPS from their host.
+	URLs map[string]string
+}
+
+// Shasum returns the SHA-256 checksum of the package of the given provider
+// for the given platform, as reported by the provider's registry.
+func (r *Registry) Shasum(p *Provider, platform string) (string, error) {
+	host, namespace, providerType, err := p.Address()
+	if err != nil {
+		return "", err
+	}
+	osName, arch, found := strings.Cut(platform, "_")
+	if !found {
+		return "", fmt.Errorf("platform '%s' is not in the form '<os>_<arch>'", platform)
+	}
+
+	baseURL, ok := r.URLs[host]
+	if !ok {
+		baseURL = "https://" + host
+	}
+	address := fmt.Sprintf("%s/v1/providers/%s/%s/%s/download/%s/%s",
+		strings.TrimSuffix(baseURL, "/"), namespace, providerType, p.Version, osName, arch,
+	)
+
+	log.Debug().Str("url", address).Msg("retrieving provider package checksum from registry")
+	resp, err := r.Client.Get(address)
+	if err != nil {
+		return "", fmt.Errorf("could not get package of '%s' for '%s': %w", p.Source, platform, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("'%s' version '%s' is not available for '%s'", p.Source, p.Version, platform)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get package of '%s' for '%s' from '%s': unexpected response status '%s'", p.Source, platform, address, resp.Status)
+	}
+
+	pkg := struct {
+		Shasum string `json:"shasum"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&pkg); err != nil {
+		return "", fmt.Errorf("could not decode package of '%s' for '%s': %w", p.Source, platform, err)
+	}
+	if pkg.Shasum == "" {
+		return "", fmt.Errorf("package of '%s' for '%s' has no checksum", p.Source, platform)
+	}
+
+	return pkg.Shasum, nil
+}
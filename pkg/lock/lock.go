@@ -0,0 +1,141 @@
+// Package lock manages the Terraform dependency lock files of Terraform roots.
+package lock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DefaultRegistryHost is the host of providers whose source address does not
+// include one.
+const DefaultRegistryHost = "registry.terraform.io"
+
+// Provider represents a provider selection in a Terraform dependency lock
+// file.
+type Provider struct {
+	Source      string
+	Version     string
+	Constraints string
+	Hashes      []string
+}
+
+// Lock represents a Terraform dependency lock file.
+type Lock struct {
+	Providers []*Provider
+}
+
+var lockFileSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "provider", LabelNames: []string{"source"}},
+	},
+}
+
+var providerSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "version", Required: true},
+		{Name: "constraints"},
+		{Name: "hashes"},
+	},
+}
+
+// Load returns the Lock parsed from the given Terraform dependency lock file.
+func Load(path string) (*Lock, error) {
+	file, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("could not parse lock file: %w", diags)
+	}
+
+	content, _, diags := file.Body.PartialContent(lockFileSchema)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("could not parse lock file: %w", diags)
+	}
+
+	l := &Lock{}
+	for _, block := range content.Blocks {
+		p := &Provider{Source: block.Labels[0]}
+
+		attrs, _, diags := block.Body.PartialContent(providerSchema)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("could not parse provider '%s' in lock file: %w", p.Source, diags)
+		}
+		for name, attr := range attrs.Attributes {
+			var err error
+			val, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("could not evaluate '%s' of provider '%s' in lock file: %w", name, p.Source, diags)
+			}
+			switch name {
+			case "version":
+				p.Version, err = stringValue(val)
+			case "constraints":
+				p.Constraints, err = stringValue(val)
+			case "hashes":
+				p.Hashes, err = stringsValue(val)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid '%s' of provider '%s' in lock file: %w", name, p.Source, err)
+			}
+		}
+
+		l.Providers = append(l.Providers, p)
+	}
+	sort.Slice(l.Providers, func(i, j int) bool { return l.Providers[i].Source < l.Providers[j].Source })
+
+	return l, nil
+}
+
+// Address returns the hostname, namespace and type of the provider's source
+// address.
+func (p *Provider) Address() (string, string, string, error) {
+	parts := strings.Split(p.Source, "/")
+	switch len(parts) {
+	case 2:
+		return DefaultRegistryHost, parts[0], parts[1], nil
+	case 3:
+		return parts[0], parts[1], parts[2], nil
+	}
+
+	return "", "", "", fmt.Errorf("invalid provider source address '%s'", p.Source)
+}
+
+// HasHash returns whether the given hash is recorded for the provider.
+func (p *Provider) HasHash(hash string) bool {
+	for _, h := range p.Hashes {
+		if h == hash {
+			return true
+		}
+	}
+
+	return false
+}
+
+func stringValue(val cty.Value) (string, error) {
+	if val.IsNull() || !val.Type().Equals(cty.String) {
+		return "", fmt.Errorf("must be a string")
+	}
+
+	return val.AsString(), nil
+}
+
+func stringsValue(val cty.Value) ([]string, error) {
+	if val.IsNull() || !(val.Type().IsListType() || val.Type().IsTupleType()) {
+		return nil, fmt.Errorf("must be a list of strings")
+	}
+
+	values := []string{}
+	for it := val.ElementIterator(); it.Next(); {
+		_, v := it.Element()
+		s, err := stringValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("must be a list of strings")
+		}
+		values = append(values, s)
+	}
+
+	return values, nil
+}
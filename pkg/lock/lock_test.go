@@ -0,0 +1,123 @@
+package lock_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/lock"
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const lockFile = `# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+provider "registry.terraform.io/hashicorp/null" {
+  version     = "3.2.1"
+  constraints = "~> 3.2"
+  hashes = [
+    "h1:ydA0/SNRVB1o95btfshvYsmxA+jZFRZcvKzZSB+4S1M=",
+    "zh:linux_amd64",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/local" {
+  version = "2.4.0"
+  hashes = [
+    "zh:linux_amd64",
+    "zh:darwin_arm64",
+  ]
+}
+`
+
+func writeLockFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), root.LockFileName)
+	require.NoError(t, os.WriteFile(path, []byte(lockFile), 0644))
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	l, err := lock.Load(writeLockFile(t))
+	require.NoError(t, err)
+
+	assert.Equal(t, []*lock.Provider{
+		{
+			Source:  "registry.terraform.io/hashicorp/local",
+			Version: "2.4.0",
+			Hashes:  []string{"zh:linux_amd64", "zh:darwin_arm64"},
+		},
+		{
+			Source:      "registry.terraform.io/hashicorp/null",
+			Version:     "3.2.1",
+			Constraints: "~> 3.2",
+			Hashes:      []string{"h1:ydA0/SNRVB1o95btfshvYsmxA+jZFRZcvKzZSB+4S1M=", "zh:linux_amd64"},
+		},
+	}, l.Providers)
+}
+
+func TestCommandCheckExecute(t *testing.T) {
+	// The test registry reports each package's checksum as its platform.
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		// <namespace>/<type>/<version>/download/<os>/<arch>
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/providers/"), "/")
+		if len(parts) != 6 || parts[3] != "download" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"os": %q, "arch": %q, "shasum": "%s_%s"}`, parts[4], parts[5], parts[4], parts[5])
+	}))
+	defer server.Close()
+
+	c := &lock.CommandCheck{
+		LockFile:   writeLockFile(t),
+		Platforms:  []string{"linux_amd64"},
+		Registries: []string{"registry.terraform.io=" + server.URL},
+	}
+	require.NoError(t, c.Execute(nil))
+	assert.Equal(t, []string{
+		"/v1/providers/hashicorp/local/2.4.0/download/linux/amd64",
+		"/v1/providers/hashicorp/null/3.2.1/download/linux/amd64",
+	}, requests)
+
+	c.Platforms = []string{"linux_amd64", "darwin_arm64"}
+	assert.ErrorContains(t, c.Execute(nil), "lacks hashes for 1 provider platforms")
+}
+
+func TestCommandSyncExecute(t *testing.T) {
+	repoRoot := t.TempDir()
+	rootModule := filepath.Join(repoRoot, "plz-out", "gen", "infra", "network", "network_root")
+	metadataFile := ".please/terraform/root.json"
+	require.NoError(t, (&root.Metadata{Target: "//infra/network:network"}).Save(filepath.Join(rootModule, metadataFile)))
+
+	venvBaseDir := filepath.Join(repoRoot, "venvs")
+	require.NoError(t, os.MkdirAll(filepath.Join(venvBaseDir, rootModule), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(venvBaseDir, rootModule, root.LockFileName), []byte(lockFile), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(repoRoot, "infra", "network"), 0750))
+
+	c := &lock.CommandSync{
+		RootModule:        rootModule,
+		VirtualEnvBaseDir: venvBaseDir,
+		PleaseOpts:        &please.Opts{PlzOutDir: "plz-out"},
+		RootOpts:          &root.Opts{MetadataFile: metadataFile},
+	}
+	// The repository root is found from the working directory in plz-out.
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(rootModule))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	require.NoError(t, c.Execute(nil))
+
+	synced, err := os.ReadFile(filepath.Join(repoRoot, "infra", "network", root.LockFileName))
+	require.NoError(t, err)
+	assert.Equal(t, lockFile, string(synced))
+}
@@ -0,0 +1,10 @@
+package migrate
+
+import "github.com/VJftw/please-terraform/internal/logging"
+
+var log = &logging.Logger
+
+// Command represents the `migrate` command and available subcommands.
+type Command struct {
+	Sources *CommandSources `command:"sources"`
+}
@@ -0,0 +1,121 @@
+package migrate
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+)
+
+// BuildRule represents a `terraform_root` or `terraform_module` rule in a
+// BUILD file.
+type BuildRule struct {
+	Kind string
+	// Label is the canonical build label of the rule.
+	Label string
+	// DepsAttr is the attribute which lists the modules used by the rule.
+	DepsAttr string
+	Deps     []string
+}
+
+// ModuleMap maps directories, relative to the repository root, to the
+// `terraform_module` targets which are defined in them.
+type ModuleMap map[string]string
+
+// FindBuildRules returns the `terraform_root` and `terraform_module` rules in
+// the BUILD files of the given repository, keyed by their package.
+func FindBuildRules(repoRoot string, buildFileNames []string) (map[string][]*BuildRule, error) {
+	rules := map[string][]*BuildRule{}
+	err := please.WalkBuildFiles(repoRoot, buildFileNames, func(pkg string, f *build.File) error {
+		for _, r := range f.Rules("") {
+			var depsAttr string
+			switch r.Kind() {
+			case "terraform_module":
+				depsAttr = "deps"
+			case "terraform_root":
+				depsAttr = "modules"
+			default:
+				continue
+			}
+			label := fmt.Sprintf("//%s:%s", pkg, r.Name())
+			deps := []string{}
+			for _, dep := range r.AttrStrings(depsAttr) {
+				deps = append(deps, canonicalise(pkg, dep))
+			}
+			rules[pkg] = append(rules[pkg], &BuildRule{
+				Kind:     r.Kind(),
+				Label:    label,
+				DepsAttr: depsAttr,
+				Deps:     deps,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// NewModuleMap returns the ModuleMap for the given rules. A directory with
+// several `terraform_module` rules maps to the one named after the directory,
+// if any, as the others cannot be told apart.
+func NewModuleMap(rules map[string][]*BuildRule) ModuleMap {
+	m := ModuleMap{}
+	for pkg, pkgRules := range rules {
+		modules := []*BuildRule{}
+		for _, r := range pkgRules {
+			if r.Kind == "terraform_module" {
+				modules = append(modules, r)
+			}
+		}
+
+		switch {
+		case len(modules) == 1:
+			m[pkg] = modules[0].Label
+		case len(modules) > 1:
+			defaultLabel := fmt.Sprintf("//%s:%s", pkg, path.Base(pkg))
+			for _, r := range modules {
+				if r.Label == defaultLabel {
+					m[pkg] = defaultLabel
+				}
+			}
+			if _, ok := m[pkg]; !ok {
+				log.Warn().Str("pkg", pkg).Msg("package has several terraform_module rules, use --module_map to choose one")
+			}
+		}
+	}
+
+	return m
+}
+
+// Set adds the given `<dir>=<label>` mapping.
+func (m ModuleMap) Set(mapping string) error {
+	dir, label, found := strings.Cut(mapping, "=")
+	if !found || !strings.HasPrefix(label, "//") {
+		return fmt.Errorf("module map '%s' is not in the form '<dir>=<label>'", mapping)
+	}
+	pkg := path.Clean(filepath.ToSlash(dir))
+	m[pkg] = canonicalise(pkg, label)
+
+	return nil
+}
+
+// canonicalise returns the canonical form of the given build label relative
+// to the given package.
+func canonicalise(pkg string, label string) string {
+	if strings.HasPrefix(label, ":") {
+		return fmt.Sprintf("//%s%s", pkg, label)
+	}
+	if strings.HasPrefix(label, "//") && !strings.Contains(label, ":") {
+		return fmt.Sprintf("%s:%s", label, path.Base(label))
+	}
+
+	return label
+}
@@ -0,0 +1,261 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+)
+
+// CommandSources represents the `migrate sources` command and its flags.
+type CommandSources struct {
+	Dir            string   `long:"dir" default:"." description:"The directory to rewrite Terraform module sources in."`
+	ModuleMap      []string `long:"module_map" description:"Maps a directory, relative to the repository root, to a terraform_module target, e.g. 'modules/vpc=//modules/vpc:vpc'. Overrides the targets found in BUILD files."`
+	BuildFileNames []string `long:"build_file_name" default:"BUILD" default:"BUILD.plz" description:"The names of Please BUILD files."`
+	DryRun         bool     `long:"dry_run" description:"Print the rewrites without modifying any files."`
+}
+
+// Rewrite represents a rewrite of a module's source.
+type Rewrite struct {
+	// Pkg is the directory, relative to the repository root, of the
+	// Terraform configuration calling the module.
+	Pkg    string
+	Module string
+	Source string
+	Label  string
+}
+
+// Execute rewrites the relative module sources in the configured directory to
+// build labels, and prints the deps which each BUILD rule must gain.
+func (c *CommandSources) Execute(args []string) error {
+	dir, err := filepath.Abs(c.Dir)
+	if err != nil {
+		return fmt.Errorf("could not resolve '%s': %w", c.Dir, err)
+	}
+
+	repoRoot, err := please.FindRepoRoot(dir)
+	if err != nil {
+		return err
+	}
+
+	rules, err := FindBuildRules(repoRoot, c.BuildFileNames)
+	if err != nil {
+		return err
+	}
+
+	moduleMap := NewModuleMap(rules)
+	for _, mapping := range c.ModuleMap {
+		if err := moduleMap.Set(mapping); err != nil {
+			return err
+		}
+	}
+
+	rewrites, err := RewriteSources(repoRoot, dir, moduleMap, c.DryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, rw := range rewrites {
+		log.Info().
+			Str("pkg", rw.Pkg).
+			Str("module", rw.Module).
+			Str("source", rw.Source).
+			Str("label", rw.Label).
+			Msg("rewrote module source")
+	}
+
+	for _, line := range MissingDeps(rewrites, rules) {
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// RewriteSources rewrites the relative module sources in the Terraform
+// configuration under the given directory to the build labels of the modules
+// they refer to. Sources which cannot be mapped to a build label are left as
+// they are.
+func RewriteSources(repoRoot string, dir string, moduleMap ModuleMap, dryRun bool) ([]*Rewrite, error) {
+	rewrites := []*Rewrite{}
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if _, ok := please.SkipDirs[d.Name()]; ok {
+			return filepath.SkipDir
+		}
+
+		relDir, err := filepath.Rel(repoRoot, p)
+		if err != nil {
+			return err
+		}
+		pkg := filepath.ToSlash(relDir)
+
+		dirRewrites, err := rewriteDir(p, pkg, moduleMap, dryRun)
+		if err != nil {
+			return err
+		}
+		rewrites = append(rewrites, dirRewrites...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not rewrite module sources in '%s': %w", dir, err)
+	}
+
+	return rewrites, nil
+}
+
+// fileEdit represents the replacement of a byte range in a file.
+type fileEdit struct {
+	start       int
+	end         int
+	replacement string
+}
+
+func rewriteDir(dir string, pkg string, moduleMap ModuleMap, dryRun bool) ([]*Rewrite, error) {
+	configFiles, err := tfconfig.ConfigFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(configFiles) == 0 {
+		return nil, nil
+	}
+
+	m, err := tfconfig.LoadDir(dir)
+	if err != nil {
+		log.Warn().Err(err).Str("path", dir).Msg("could not fully parse Terraform configuration")
+	}
+	if m == nil {
+		return nil, nil
+	}
+
+	rewrites := []*Rewrite{}
+	edits := map[string][]*fileEdit{}
+	for _, mc := range m.ModuleCalls {
+		if !tfconfig.IsLocalSource(mc.Source) {
+			continue
+		}
+
+		modulePkg := path.Clean(path.Join(pkg, mc.Source))
+		label, ok := moduleMap[modulePkg]
+		if !ok {
+			log.Warn().
+				Str("pkg", pkg).
+				Str("module", mc.Name).
+				Str("source", mc.Source).
+				Msg("could not find a terraform_module for module source, use --module_map to add one")
+			continue
+		}
+
+		rewrites = append(rewrites, &Rewrite{
+			Pkg:    pkg,
+			Module: mc.Name,
+			Source: mc.Source,
+			Label:  label,
+		})
+		filename := mc.SourceRange.Filename
+		edits[filename] = append(edits[filename], &fileEdit{
+			start:       mc.SourceRange.Start.Byte,
+			end:         mc.SourceRange.End.Byte,
+			replacement: strconv.Quote(label),
+		})
+	}
+
+	if dryRun {
+		return rewrites, nil
+	}
+
+	for filename, fileEdits := range edits {
+		if err := applyEdits(filename, fileEdits); err != nil {
+			return nil, err
+		}
+	}
+
+	return rewrites, nil
+}
+
+// applyEdits applies the given edits to the given file, from last to first
+// so that the byte offsets of earlier edits remain valid.
+func applyEdits(filename string, edits []*fileEdit) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		data = append(data[:e.start:e.start], append([]byte(e.replacement), data[e.end:]...)...)
+	}
+
+	if err := os.WriteFile(filename, data, info.Mode()); err != nil {
+		return fmt.Errorf("could not write '%s': %w", filename, err)
+	}
+
+	return nil
+}
+
+// MissingDeps returns a line for each module target which a BUILD rule must
+// gain as a dep for the given rewrites, e.g.
+// `//infra/app:app (modules): //modules/vpc:vpc`.
+func MissingDeps(rewrites []*Rewrite, rules map[string][]*BuildRule) []string {
+	missing := map[string][]string{}
+	seen := map[string]struct{}{}
+	for _, rw := range rewrites {
+		// The rule which owns the configuration can only be told apart when
+		// the package has a single Terraform rule.
+		owner := "//" + rw.Pkg
+		if pkgRules := rules[rw.Pkg]; len(pkgRules) == 1 {
+			owner = fmt.Sprintf("%s (%s)", pkgRules[0].Label, pkgRules[0].DepsAttr)
+			if contains(pkgRules[0].Deps, rw.Label) {
+				continue
+			}
+		}
+
+		key := owner + " " + rw.Label
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		missing[owner] = append(missing[owner], rw.Label)
+	}
+
+	owners := make([]string, 0, len(missing))
+	for owner := range missing {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	lines := []string{}
+	for _, owner := range owners {
+		labels := missing[owner]
+		sort.Strings(labels)
+		for _, label := range labels {
+			lines = append(lines, fmt.Sprintf("%s: %s", owner, label))
+		}
+	}
+
+	return lines
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,127 @@
+package migrate_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteSources(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, map[string]string{
+		"infra/app/BUILD": `terraform_root(
+    name = "app",
+    srcs = ["main.tf"],
+    modules = ["//modules/subnet"],
+)
+`,
+		"infra/app/main.tf": `module "vpc" {
+  source = "../../modules/vpc"
+  cidr   = "10.0.0.0/16"
+}
+
+module "subnet" {
+  source = "../../modules/subnet"
+}
+
+module "unknown" {
+  source = "../../modules/unknown"
+}
+
+module "label" {
+  source  = "cloudposse/label/null"
+  version = "0.25.0"
+}
+`,
+		"modules/vpc/BUILD": `terraform_module(
+    name = "vpc",
+    srcs = ["main.tf"],
+)
+`,
+		"modules/vpc/main.tf":        `module "subnet" { source = "./subnet" }`,
+		"modules/vpc/subnet/main.tf": "",
+		"modules/subnet/BUILD": `terraform_module(
+    name = "subnet",
+    srcs = ["main.tf"],
+)
+`,
+		"modules/subnet/main.tf": "",
+	})
+
+	rules, err := migrate.FindBuildRules(repo, []string{"BUILD"})
+	require.NoError(t, err)
+
+	moduleMap := migrate.NewModuleMap(rules)
+	assert.Equal(t, migrate.ModuleMap{
+		"modules/vpc":    "//modules/vpc:vpc",
+		"modules/subnet": "//modules/subnet:subnet",
+	}, moduleMap)
+	require.NoError(t, moduleMap.Set("modules/vpc/subnet=//modules/vpc:subnet"))
+
+	rewrites, err := migrate.RewriteSources(repo, repo, moduleMap, false)
+	require.NoError(t, err)
+	assert.Equal(t, []*migrate.Rewrite{
+		{Pkg: "infra/app", Module: "vpc", Source: "../../modules/vpc", Label: "//modules/vpc:vpc"},
+		{Pkg: "infra/app", Module: "subnet", Source: "../../modules/subnet", Label: "//modules/subnet:subnet"},
+		{Pkg: "modules/vpc", Module: "subnet", Source: "./subnet", Label: "//modules/vpc:subnet"},
+	}, rewrites)
+
+	contents, err := os.ReadFile(filepath.Join(repo, "infra/app/main.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, `module "vpc" {
+  source = "//modules/vpc:vpc"
+  cidr   = "10.0.0.0/16"
+}
+
+module "subnet" {
+  source = "//modules/subnet:subnet"
+}
+
+module "unknown" {
+  source = "../../modules/unknown"
+}
+
+module "label" {
+  source  = "cloudposse/label/null"
+  version = "0.25.0"
+}
+`, string(contents))
+
+	assert.Equal(t, []string{
+		"//infra/app:app (modules): //modules/vpc:vpc",
+		"//modules/vpc:vpc (deps): //modules/vpc:subnet",
+	}, migrate.MissingDeps(rewrites, rules))
+}
+
+func TestRewriteSourcesDryRun(t *testing.T) {
+	repo := t.TempDir()
+	mainTf := `module "vpc" {
+  source = "../vpc"
+}
+`
+	writeFiles(t, repo, map[string]string{
+		"app/main.tf": mainTf,
+		"vpc/main.tf": "",
+	})
+
+	rewrites, err := migrate.RewriteSources(repo, repo, migrate.ModuleMap{"vpc": "//vpc:vpc"}, true)
+	require.NoError(t, err)
+	assert.Len(t, rewrites, 1)
+
+	contents, err := os.ReadFile(filepath.Join(repo, "app/main.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, mainTf, string(contents))
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+}
@@ -8,6 +8,8 @@ type Opts struct {
 
 // Command represents the `module` command and available subcommands.
 type Command struct {
+	Docs     *CommandDocs     `command:"docs"`
 	Local    *CommandLocal    `command:"local"`
 	Registry *CommandRegistry `command:"registry"`
+	Vendor   *CommandVendor   `command:"vendor"`
 }
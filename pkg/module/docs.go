@@ -0,0 +1,284 @@
+package module
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+)
+
+const (
+	// DocsBeginMarker marks the beginning of the generated section of a README.
+	DocsBeginMarker = "<!-- BEGIN_PLEASE_TERRAFORM_DOCS -->"
+	// DocsEndMarker marks the end of the generated section of a README.
+	DocsEndMarker = "<!-- END_PLEASE_TERRAFORM_DOCS -->"
+)
+
+// CommandDocs represents the `module docs` command and its flags.
+type CommandDocs struct {
+	Module string `long:"module" required:"true" description:"The built Terraform module to document."`
+	Format string `long:"format" choice:"markdown" choice:"json" default:"markdown" description:"The format to render the documentation in."`
+	Readme string `long:"readme" description:"A README to write the Markdown documentation into, between the '<!-- BEGIN_PLEASE_TERRAFORM_DOCS -->' and '<!-- END_PLEASE_TERRAFORM_DOCS -->' markers."`
+	Check  bool   `long:"check" description:"Fail if the documentation in --readme is out of date instead of updating it."`
+
+	Opts *Opts
+}
+
+// Docs represents the documentation of a Terraform module.
+type Docs struct {
+	Target    string          `json:"target"`
+	Aliases   []string        `json:"aliases"`
+	Providers []*DocsProvider `json:"providers"`
+	Inputs    []*DocsInput    `json:"inputs"`
+	Outputs   []*DocsOutput   `json:"outputs"`
+}
+
+// DocsProvider represents the documentation of a provider required by a
+// Terraform module.
+type DocsProvider struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Version string `json:"version"`
+}
+
+// DocsInput represents the documentation of a Terraform module's variable.
+type DocsInput struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Required    bool        `json:"required"`
+	Sensitive   bool        `json:"sensitive"`
+}
+
+// DocsOutput represents the documentation of a Terraform module's output.
+type DocsOutput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Sensitive   bool   `json:"sensitive"`
+}
+
+// Execute renders the documentation of the configured module to stdout, or
+// into the configured README.
+func (c *CommandDocs) Execute(args []string) error {
+	docs, err := LoadDocs(c.Module, c.Opts.MetadataFile)
+	if err != nil {
+		return err
+	}
+
+	if c.Readme == "" {
+		if c.Check {
+			return fmt.Errorf("--check requires --readme")
+		}
+		if c.Format == "json" {
+			return docs.WriteJSON(os.Stdout)
+		}
+		return docs.WriteMarkdown(os.Stdout)
+	}
+
+	if c.Format != "markdown" {
+		return fmt.Errorf("only markdown documentation can be written into a README")
+	}
+
+	readme, err := os.ReadFile(c.Readme)
+	if err != nil {
+		return fmt.Errorf("could not read README '%s': %w", c.Readme, err)
+	}
+
+	markdown := &bytes.Buffer{}
+	if err := docs.WriteMarkdown(markdown); err != nil {
+		return err
+	}
+
+	updated, err := ReplaceDocsSection(readme, markdown.Bytes())
+	if err != nil {
+		return fmt.Errorf("could not update README '%s': %w", c.Readme, err)
+	}
+
+	if c.Check {
+		if !bytes.Equal(readme, updated) {
+			return fmt.Errorf("the documentation in '%s' for %s is out of date, run 'please_terraform module docs --readme' to update it", c.Readme, docs.Target)
+		}
+		log.Info().Str("path", c.Readme).Msg("documentation is up to date")
+		return nil
+	}
+
+	if err := os.WriteFile(c.Readme, updated, 0644); err != nil {
+		return fmt.Errorf("could not write README '%s': %w", c.Readme, err)
+	}
+	log.Info().Str("path", c.Readme).Msg("updated documentation")
+
+	return nil
+}
+
+// LoadDocs returns the documentation of the given built module.
+func LoadDocs(moduleDir string, metadataFile string) (*Docs, error) {
+	meta, err := Load(filepath.Join(moduleDir, metadataFile))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := tfconfig.LoadDir(moduleDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse Terraform configuration in '%s': %w", moduleDir, err)
+	}
+
+	docs := &Docs{
+		Target:    meta.Target,
+		Aliases:   meta.Aliases,
+		Providers: []*DocsProvider{},
+		Inputs:    []*DocsInput{},
+		Outputs:   []*DocsOutput{},
+	}
+
+	for _, p := range cfg.RequiredProviders {
+		docs.Providers = append(docs.Providers, &DocsProvider{
+			Name:    p.Name,
+			Source:  p.Source,
+			Version: p.Version,
+		})
+	}
+
+	for _, v := range cfg.Variables {
+		docs.Inputs = append(docs.Inputs, &DocsInput{
+			Name:        v.Name,
+			Description: v.Description,
+			Type:        v.Type,
+			Default:     v.Default,
+			Required:    v.Required,
+			Sensitive:   v.Sensitive,
+		})
+	}
+	sort.Slice(docs.Inputs, func(i, j int) bool { return docs.Inputs[i].Name < docs.Inputs[j].Name })
+
+	for _, o := range cfg.Outputs {
+		docs.Outputs = append(docs.Outputs, &DocsOutput{
+			Name:        o.Name,
+			Description: o.Description,
+			Sensitive:   o.Sensitive,
+		})
+	}
+	sort.Slice(docs.Outputs, func(i, j int) bool { return docs.Outputs[i].Name < docs.Outputs[j].Name })
+
+	return docs, nil
+}
+
+// WriteJSON writes the documentation as JSON.
+func (d *Docs) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(d)
+}
+
+// WriteMarkdown writes the documentation as Markdown tables.
+func (d *Docs) WriteMarkdown(w io.Writer) error {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "## Please\n\n")
+	fmt.Fprintf(b, "| Target | Aliases |\n|--------|---------|\n")
+	aliases := make([]string, 0, len(d.Aliases))
+	for _, alias := range d.Aliases {
+		aliases = append(aliases, code(alias))
+	}
+	fmt.Fprintf(b, "| %s | %s |\n", code(d.Target), strings.Join(aliases, "<br>"))
+
+	fmt.Fprintf(b, "\n## Providers\n\n")
+	if len(d.Providers) == 0 {
+		fmt.Fprintf(b, "No providers.\n")
+	} else {
+		fmt.Fprintf(b, "| Name | Source | Version |\n|------|--------|---------|\n")
+		for _, p := range d.Providers {
+			fmt.Fprintf(b, "| %s | %s | %s |\n", p.Name, orNA(p.Source), orNA(code(p.Version)))
+		}
+	}
+
+	fmt.Fprintf(b, "\n## Inputs\n\n")
+	if len(d.Inputs) == 0 {
+		fmt.Fprintf(b, "No inputs.\n")
+	} else {
+		fmt.Fprintf(b, "| Name | Description | Type | Default | Required |\n|------|-------------|------|---------|:--------:|\n")
+		for _, i := range d.Inputs {
+			defaultValue := "n/a"
+			if !i.Required {
+				defaultBytes, err := json.Marshal(i.Default)
+				if err != nil {
+					return fmt.Errorf("could not marshal default of '%s': %w", i.Name, err)
+				}
+				defaultValue = code(string(defaultBytes))
+			}
+			fmt.Fprintf(b, "| %s | %s | %s | %s | %s |\n",
+				i.Name, cell(i.Description), orNA(code(i.Type)), defaultValue, yesNo(i.Required))
+		}
+	}
+
+	fmt.Fprintf(b, "\n## Outputs\n\n")
+	if len(d.Outputs) == 0 {
+		fmt.Fprintf(b, "No outputs.\n")
+	} else {
+		fmt.Fprintf(b, "| Name | Description | Sensitive |\n|------|-------------|:---------:|\n")
+		for _, o := range d.Outputs {
+			fmt.Fprintf(b, "| %s | %s | %s |\n", o.Name, cell(o.Description), yesNo(o.Sensitive))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// ReplaceDocsSection returns the given README with the contents between the
+// docs markers replaced by the given docs.
+func ReplaceDocsSection(readme []byte, docs []byte) ([]byte, error) {
+	begin := bytes.Index(readme, []byte(DocsBeginMarker))
+	end := bytes.Index(readme, []byte(DocsEndMarker))
+	if begin < 0 || end < 0 || end < begin {
+		return nil, fmt.Errorf("could not find '%s' followed by '%s'", DocsBeginMarker, DocsEndMarker)
+	}
+
+	updated := &bytes.Buffer{}
+	updated.Write(readme[:begin+len(DocsBeginMarker)])
+	updated.WriteString("\n")
+	updated.Write(docs)
+	updated.Write(readme[end:])
+
+	return updated.Bytes(), nil
+}
+
+// cell escapes the given text for a Markdown table cell.
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(strings.TrimSpace(s), "\n", "<br>")
+}
+
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	// Collapse multi-line types, e.g. objects, onto a single line.
+	fields := strings.Fields(s)
+	return "`" + cell(strings.Join(fields, " ")) + "`"
+}
+
+func orNA(s string) string {
+	if s == "" {
+		return "n/a"
+	}
+
+	return s
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
@@ -0,0 +1,127 @@
+package module_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const docsMetadataFile = ".please/terraform/module.json"
+
+func writeDocsModule(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, (&module.Metadata{
+		Target:  "//modules/label:label",
+		Aliases: []string{"//modules/label:label", "//modules/label"},
+	}).Save(filepath.Join(dir, docsMetadataFile)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`
+terraform {
+  required_providers {
+    null = {
+      source  = "hashicorp/null"
+      version = ">= 3.0"
+    }
+  }
+}
+
+variable "name" {
+  description = "The name | label."
+  type        = string
+}
+
+variable "tags" {
+  type = object({
+    owner = optional(string)
+  })
+  default = { owner = "platform" }
+}
+
+output "id" {
+  description = "The ID of the label."
+  value       = var.name
+  sensitive   = true
+}
+`), 0644))
+
+	return dir
+}
+
+func TestDocsWriteMarkdown(t *testing.T) {
+	docs, err := module.LoadDocs(writeDocsModule(t), docsMetadataFile)
+	require.NoError(t, err)
+
+	markdown := &bytes.Buffer{}
+	require.NoError(t, docs.WriteMarkdown(markdown))
+	assert.Equal(t, "## Please\n"+
+		"\n"+
+		"| Target | Aliases |\n"+
+		"|--------|---------|\n"+
+		"| `//modules/label:label` | `//modules/label:label`<br>`//modules/label` |\n"+
+		"\n"+
+		"## Providers\n"+
+		"\n"+
+		"| Name | Source | Version |\n"+
+		"|------|--------|---------|\n"+
+		"| null | hashicorp/null | `>= 3.0` |\n"+
+		"\n"+
+		"## Inputs\n"+
+		"\n"+
+		"| Name | Description | Type | Default | Required |\n"+
+		"|------|-------------|------|---------|:--------:|\n"+
+		"| name | The name \\| label. | `string` | n/a | yes |\n"+
+		"| tags |  | `object({ owner = optional(string) })` | `{\"owner\":\"platform\"}` | no |\n"+
+		"\n"+
+		"## Outputs\n"+
+		"\n"+
+		"| Name | Description | Sensitive |\n"+
+		"|------|-------------|:---------:|\n"+
+		"| id | The ID of the label. | yes |\n", markdown.String())
+}
+
+func TestDocsWriteJSON(t *testing.T) {
+	docs, err := module.LoadDocs(writeDocsModule(t), docsMetadataFile)
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, docs.WriteJSON(out))
+	assert.JSONEq(t, `{
+  "target": "//modules/label:label",
+  "aliases": ["//modules/label:label", "//modules/label"],
+  "providers": [{"name": "null", "source": "hashicorp/null", "version": ">= 3.0"}],
+  "inputs": [
+    {"name": "name", "description": "The name | label.", "type": "string", "default": null, "required": true, "sensitive": false},
+    {"name": "tags", "description": "", "type": "object({\n    owner = optional(string)\n  })", "default": {"owner": "platform"}, "required": false, "sensitive": false}
+  ],
+  "outputs": [{"name": "id", "description": "The ID of the label.", "sensitive": true}]
+}`, out.String())
+}
+
+func TestCommandDocsCheck(t *testing.T) {
+	moduleDir := writeDocsModule(t)
+	readme := filepath.Join(t.TempDir(), "README.md")
+	require.NoError(t, os.WriteFile(readme, []byte("# Label\n\n"+module.DocsBeginMarker+"\nstale\n"+module.DocsEndMarker+"\n"), 0644))
+
+	c := &module.CommandDocs{
+		Module: moduleDir,
+		Format: "markdown",
+		Readme: readme,
+		Check:  true,
+		Opts:   &module.Opts{MetadataFile: docsMetadataFile},
+	}
+	assert.ErrorContains(t, c.Execute(nil), "is out of date")
+
+	c.Check = false
+	require.NoError(t, c.Execute(nil))
+	contents, err := os.ReadFile(readme)
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), module.DocsBeginMarker+"\n## Please\n")
+	assert.Contains(t, string(contents), "| id | The ID of the label. | yes |\n"+module.DocsEndMarker+"\n")
+
+	c.Check = true
+	assert.NoError(t, c.Execute(nil))
+}
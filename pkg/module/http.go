@@ -0,0 +1,215 @@
+package module
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// HTTPOpts represents the options of the HTTP client used to talk to
+// Terraform registries and to download modules.
+type HTTPOpts struct {
+	Timeout        time.Duration `long:"http_timeout" default:"60s" description:"The time to wait for a response's headers from a registry."`
+	ConnectTimeout time.Duration `long:"http_connect_timeout" default:"10s" description:"The time to wait to connect to a registry, including the TLS handshake."`
+	Retries        int           `long:"http_retries" default:"5" description:"The number of times to retry requests which fail or receive a 5xx or 429 response."`
+	RetryWaitMin   time.Duration `long:"http_retry_wait_min" default:"1s" description:"The time to wait before the first retry, doubled for every following retry."`
+	RetryWaitMax   time.Duration `long:"http_retry_wait_max" default:"30s" description:"The longest time to wait before a retry, including waits requested by Retry-After headers."`
+	CABundle       string        `long:"ca_bundle" env:"PLEASE_TERRAFORM_CA_BUNDLE" description:"A PEM file of CA certificates to trust in addition to the system's."`
+}
+
+// DefaultHTTPOpts are the HTTPOpts used when none are configured.
+var DefaultHTTPOpts = HTTPOpts{
+	Timeout:        60 * time.Second,
+	ConnectTimeout: 10 * time.Second,
+	Retries:        5,
+	RetryWaitMin:   1 * time.Second,
+	RetryWaitMax:   30 * time.Second,
+}
+
+// NewHTTPClient returns an HTTP client configured with the given options,
+// which retries failed requests with exponential backoff and uses the proxy
+// from the standard `HTTP_PROXY`, `HTTPS_PROXY` and `NO_PROXY` environment
+// variables.
+func NewHTTPClient(o *HTTPOpts) (*http.Client, error) {
+	if o == nil {
+		o = &DefaultHTTPOpts
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   o.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   o.ConnectTimeout,
+		ResponseHeaderTimeout: o.Timeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if o.CABundle != "" {
+		pool, err := loadCABundle(o.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+		}
+	}
+
+	// The client has no overall timeout as module downloads may be large.
+	return &http.Client{
+		Transport: &retryTransport{
+			base:    transport,
+			retries: o.Retries,
+			waitMin: o.RetryWaitMin,
+			waitMax: o.RetryWaitMax,
+		},
+	}, nil
+}
+
+// NewGetters returns the `hashicorp/go-getter` getters with the HTTP getters
+// replaced by ones which use the given client.
+func NewGetters(client *http.Client) map[string]getter.Getter {
+	getters := map[string]getter.Getter{}
+	for scheme, g := range getter.Getters {
+		getters[scheme] = g
+	}
+
+	httpGetter := &getter.HttpGetter{
+		Client: client,
+		Netrc:  true,
+	}
+	getters["http"] = httpGetter
+	getters["https"] = httpGetter
+
+	return getters
+}
+
+// loadCABundle returns the system's certificate pool with the certificates in
+// the given PEM file added.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Warn().Err(err).Msg("could not load system certificates, only trusting the CA bundle")
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("could not find any certificates in CA bundle '%s'", path)
+	}
+
+	return pool, nil
+}
+
+// retryTransport is an http.RoundTripper which retries requests which fail or
+// receive a 5xx or 429 response.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	waitMin time.Duration
+	waitMax time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if !shouldRetry(resp, err) || attempt >= t.retries || !canReplay(req) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+		logger := log.Warn().Str("url", req.URL.String()).Int("attempt", attempt+1).Dur("wait", wait)
+		if err != nil {
+			logger = logger.Err(err)
+		} else {
+			logger = logger.Int("status", resp.StatusCode)
+			// Drain the body so that the connection can be re-used.
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		logger.Msg("retrying request")
+
+		if req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns how long to wait before retrying the given attempt,
+// honouring the response's Retry-After header if it has one.
+func (t *retryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	wait := time.Duration(float64(t.waitMin) * math.Pow(2, float64(attempt)))
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			wait = retryAfter
+		}
+	}
+	if wait > t.waitMax {
+		wait = t.waitMax
+	}
+
+	return wait
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// canReplay returns whether the given request's body can be sent again.
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
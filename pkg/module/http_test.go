@@ -0,0 +1,114 @@
+package module_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testHTTPOpts = &module.HTTPOpts{
+	Timeout:        time.Second,
+	ConnectTimeout: time.Second,
+	Retries:        3,
+	RetryWaitMin:   time.Millisecond,
+	RetryWaitMax:   10 * time.Millisecond,
+}
+
+// newFlakyServer returns a server which responds with the given statuses in
+// order, and then with 204s.
+func newFlakyServer(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := atomic.AddInt32(&requests, 1) - 1
+		if int(i) < len(statuses) {
+			if statuses[i] == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "0")
+			}
+			w.WriteHeader(statuses[i])
+			return
+		}
+		w.Header().Set("X-Terraform-Get", "https://example.com/module.tar.gz")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestHTTPClientRetries(t *testing.T) {
+	var tests = []struct {
+		description      string
+		statuses         []int
+		expectedStatus   int
+		expectedRequests int32
+	}{
+		{
+			description:      "succeeds without retrying",
+			expectedStatus:   http.StatusNoContent,
+			expectedRequests: 1,
+		},
+		{
+			description:      "retries 5xx and 429 responses",
+			statuses:         []int{http.StatusBadGateway, http.StatusTooManyRequests},
+			expectedStatus:   http.StatusNoContent,
+			expectedRequests: 3,
+		},
+		{
+			description:      "does not retry 4xx responses",
+			statuses:         []int{http.StatusNotFound},
+			expectedStatus:   http.StatusNotFound,
+			expectedRequests: 1,
+		},
+		{
+			description:      "gives up after the configured retries",
+			statuses:         []int{500, 500, 500, 500, 500},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedRequests: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			server, requests := newFlakyServer(t, tt.statuses...)
+			client, err := module.NewHTTPClient(testHTTPOpts)
+			require.NoError(t, err)
+
+			resp, err := client.Get(server.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedRequests, atomic.LoadInt32(requests))
+		})
+	}
+}
+
+func TestHTTPClientCABundle(t *testing.T) {
+	_, err := module.NewHTTPClient(&module.HTTPOpts{CABundle: "testdata/missing.pem"})
+	assert.ErrorContains(t, err, "could not read CA bundle")
+}
+
+func TestGetDownloadURL(t *testing.T) {
+	server, _ := newFlakyServer(t, http.StatusServiceUnavailable)
+	c := &module.CommandRegistry{
+		Registry:   server.URL,
+		Namespace:  "cloudposse",
+		ModuleName: "label",
+		Provider:   "null",
+		Version:    "0.25.0",
+		HTTP:       testHTTPOpts,
+	}
+	downloadURL, err := c.GetDownloadURL()
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/module.tar.gz", downloadURL)
+
+	server, _ = newFlakyServer(t, http.StatusNotFound)
+	c = &module.CommandRegistry{Registry: server.URL, HTTP: testHTTPOpts}
+	_, err = c.GetDownloadURL()
+	assert.ErrorContains(t, err, "unexpected response status '404 Not Found'")
+}
@@ -82,13 +82,19 @@ func (c *CommandLocal) Execute(args []string) error {
 				return fmt.Errorf("could not copy file: %w", err)
 			}
 		}
+		m.Srcs = srcs
 	}
 
 	log.Debug().Strs("deps", c.Deps).Msg("colocating modules")
 	// colocate modules
-	if err := ColocateModules(c.Opts.MetadataFile, c.Out, c.Deps); err != nil {
+	depTargets, err := ColocateModules(c.Opts.MetadataFile, c.Out, c.Deps, nil)
+	if err != nil {
 		return err
 	}
+	if err := CheckUnresolvedSources(c.Out, "deps"); err != nil {
+		return err
+	}
+	m.Deps = depTargets
 
 	log.Debug().Str("path", c.Opts.MetadataFile).Msg("saving metadata")
 
@@ -60,6 +60,7 @@ func TestCommandLocalExecute(t *testing.T) {
 
 			absSrcs := generateSrcs(t, tt.srcFileNames)
 			tt.cmdLocal.Srcs = strings.Join(absSrcs, " ")
+			tt.expectedMetadata.Srcs = absSrcs
 
 			outDir, err := os.MkdirTemp("", "test_command_local_execute_dest_*")
 			require.NoError(t, err)
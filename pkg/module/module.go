@@ -5,17 +5,28 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/VJftw/please-terraform/internal/logging"
 	"github.com/VJftw/please-terraform/pkg/please"
 )
 
-var log = logging.NewLogger()
+var log = &logging.Logger
 
 // Metadata represents a module's metadata.
 type Metadata struct {
 	Target  string
 	Aliases []string
+	// Srcs are the source files of the module relative to the repository root.
+	Srcs []string `json:",omitempty"`
+	// Deps are the targets of the modules that this module depends on.
+	Deps []string `json:",omitempty"`
+	// Licences are the SPDX identifiers of the licences detected in a
+	// registry module.
+	Licences []string `json:",omitempty"`
+	// Version is the version of a registry module.
+	Version string `json:",omitempty"`
 }
 
 // Load returns a module's Metadata loaded from the given directory.
@@ -66,40 +77,164 @@ func (m *Metadata) StripDirs(out string, strip []string) error {
 }
 
 // ColocateModules colocates the given module paths to the given out directory.
-func ColocateModules(metadataFilePath string, out string, modulePaths []string) error {
+// It returns the targets of the colocated modules. The aliases of the modules
+// must not conflict, except for those given in overrides which map an alias to
+// the target which wins it.
+func ColocateModules(metadataFilePath string, out string, modulePaths []string, overrides map[string]string) ([]string, error) {
 	log.Debug().Strs("modulePaths", modulePaths).Msg("colocating modules")
+	targets := []string{}
 	if len(modulePaths) < 1 {
 		log.Debug().Msg("no modules to colocate")
-		return nil
+		return targets, nil
 	}
 
 	modulesDir := filepath.Join(out, ".modules")
 	if err := os.MkdirAll(modulesDir, 0750); err != nil {
-		return fmt.Errorf("could not create modules dir '%s': %w", modulesDir, err)
+		return nil, fmt.Errorf("could not create modules dir '%s': %w", modulesDir, err)
 	}
 
+	table := NewAliasTable()
 	for _, modulePath := range modulePaths {
-		replace := fmt.Sprintf(".%c%s", filepath.Separator, filepath.Join(".modules", modulePath))
-
 		moduleMeta, err := Load(filepath.Join(modulePath, metadataFilePath))
 		if err != nil {
-			return err
+			return nil, err
 		}
+		targets = append(targets, moduleMeta.Target)
 
-		for _, alias := range moduleMeta.Aliases {
-			log.Debug().Str("alias", alias).Str("path", replace).Msg("replacing in module")
-			if err := please.ReplaceInDirectory(out, alias, replace); err != nil {
-				return err
-			}
+		replace := fmt.Sprintf(".%c%s", filepath.Separator, filepath.Join(".modules", modulePath))
+		table.Add(moduleMeta, replace)
+	}
+
+	aliases, err := table.Resolve(overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, target := range UnusedModules(out, aliases) {
+		log.Warn().Str("target", target).Msg("module is a dependency but no module block uses it")
+	}
+
+	if err := CheckModuleVersions(out, aliases); err != nil {
+		return nil, err
+	}
+
+	for _, alias := range aliases {
+		log.Debug().Str("alias", alias.Alias).Str("path", alias.Path).Msg("replacing in module")
+		if err := please.ReplaceInDirectory(out, alias.Alias, alias.Path); err != nil {
+			return nil, err
 		}
+	}
 
-		if err := os.MkdirAll(filepath.Dir(filepath.Join(out, replace)), 0750); err != nil {
-			return err
+	for _, modulePath := range modulePaths {
+		dest := filepath.Join(modulesDir, modulePath)
+		if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+			return nil, err
 		}
-		if err := please.Sync(modulePath, filepath.Join(out, replace), []string{}); err != nil {
-			return err
+		if err := please.Sync(modulePath, dest, []string{}); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	return targets, nil
+}
+
+// Alias represents an alias of a colocated module.
+type Alias struct {
+	Alias  string
+	Target string
+	// Version is the version of the module if it is a registry module.
+	Version string
+	// Path is the path which the alias is replaced with.
+	Path string
+}
+
+// AliasTable holds the aliases of the modules being colocated together.
+type AliasTable struct {
+	aliases []*Alias
+}
+
+// NewAliasTable returns an empty AliasTable.
+func NewAliasTable() *AliasTable {
+	return &AliasTable{aliases: []*Alias{}}
+}
+
+// Add adds the aliases of the given module, which is colocated at the given
+// path.
+func (t *AliasTable) Add(m *Metadata, path string) {
+	for _, alias := range m.Aliases {
+		t.aliases = append(t.aliases, &Alias{Alias: alias, Target: m.Target, Version: m.Version, Path: path})
+	}
+}
+
+// Resolve returns the aliases to replace, longest first so that an alias is
+// never replaced within a longer one. It fails if aliases of different
+// targets are duplicates, unless an override chooses the target for the
+// alias, or if one is a prefix of another, unless both are Please build
+// labels in the same or nested packages.
+func (t *AliasTable) Resolve(overrides map[string]string) ([]*Alias, error) {
+	targets := map[string]struct{}{}
+	for _, a := range t.aliases {
+		targets[a.Target] = struct{}{}
+	}
+	for alias, target := range overrides {
+		if _, ok := targets[target]; !ok {
+			return nil, fmt.Errorf("override of alias '%s' chooses '%s' which is not a module being colocated", alias, target)
+		}
+	}
+
+	byAlias := map[string]*Alias{}
+	for _, a := range t.aliases {
+		if target, ok := overrides[a.Alias]; ok && target != a.Target {
+			log.Debug().Str("alias", a.Alias).Str("target", a.Target).Str("override", target).Msg("alias overridden")
+			continue
+		}
+
+		existing, ok := byAlias[a.Alias]
+		if ok && existing.Target != a.Target {
+			return nil, fmt.Errorf(
+				"alias '%s' is declared by both '%s' and '%s', add a module override to choose one",
+				a.Alias, existing.Target, a.Target,
+			)
+		}
+		byAlias[a.Alias] = a
+	}
+
+	resolved := make([]*Alias, 0, len(byAlias))
+	for _, a := range byAlias {
+		resolved = append(resolved, a)
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		if len(resolved[i].Alias) != len(resolved[j].Alias) {
+			return len(resolved[i].Alias) > len(resolved[j].Alias)
+		}
+		return resolved[i].Alias < resolved[j].Alias
+	})
+
+	for i, long := range resolved {
+		for _, short := range resolved[i+1:] {
+			if short.Target == long.Target || !strings.HasPrefix(long.Alias, short.Alias) {
+				continue
+			}
+			if isNestedLabel(short.Alias, long.Alias) {
+				continue
+			}
+			return nil, fmt.Errorf(
+				"alias '%s' of '%s' overlaps alias '%s' of '%s'",
+				short.Alias, short.Target, long.Alias, long.Target,
+			)
+		}
+	}
+
+	return resolved, nil
+}
+
+// isNestedLabel returns whether the given long alias is a Please build label
+// in the same package as, or a package nested under, the given short alias.
+func isNestedLabel(short string, long string) bool {
+	if !strings.HasPrefix(short, "//") || strings.Contains(short, ":") {
+		return false
+	}
+	rest := strings.TrimPrefix(long, short)
+
+	return strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, "/")
 }
@@ -0,0 +1,126 @@
+package module_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasTableResolve(t *testing.T) {
+	label025 := &module.Metadata{
+		Target:  "//third_party/terraform:label_0_25",
+		Aliases: []string{"//third_party/terraform:label_0_25", "cloudposse/label/null"},
+	}
+	label026 := &module.Metadata{
+		Target:  "//third_party/terraform:label_0_26",
+		Aliases: []string{"//third_party/terraform:label_0_26", "cloudposse/label/null"},
+	}
+
+	var tests = []struct {
+		description     string
+		modules         []*module.Metadata
+		overrides       map[string]string
+		expectedAliases []string
+		expectedErr     string
+	}{
+		{
+			description: "replaces longest aliases first",
+			modules: []*module.Metadata{
+				{Target: "//modules/a:a", Aliases: []string{"//modules/a:a", "//modules/a"}},
+				{Target: "//modules/a:b", Aliases: []string{"//modules/a:b"}},
+				{Target: "//modules/a/c:c", Aliases: []string{"//modules/a/c:c", "//modules/a/c"}},
+			},
+			expectedAliases: []string{"//modules/a/c:c", "//modules/a/c", "//modules/a:a", "//modules/a:b", "//modules/a"},
+		},
+		{
+			description: "fails on duplicate aliases",
+			modules:     []*module.Metadata{label025, label026},
+			expectedErr: "alias 'cloudposse/label/null' is declared by both '//third_party/terraform:label_0_25' and '//third_party/terraform:label_0_26'",
+		},
+		{
+			description:     "overrides duplicate aliases",
+			modules:         []*module.Metadata{label025, label026},
+			overrides:       map[string]string{"cloudposse/label/null": "//third_party/terraform:label_0_26"},
+			expectedAliases: []string{"//third_party/terraform:label_0_25", "//third_party/terraform:label_0_26", "cloudposse/label/null"},
+		},
+		{
+			description: "fails on overrides of modules which are not colocated",
+			modules:     []*module.Metadata{label025},
+			overrides:   map[string]string{"cloudposse/label/null": "//third_party/terraform:label_0_26"},
+			expectedErr: "chooses '//third_party/terraform:label_0_26' which is not a module being colocated",
+		},
+		{
+			description: "fails on prefix overlapping aliases",
+			modules: []*module.Metadata{
+				{Target: "//modules:label", Aliases: []string{"//modules:label"}},
+				{Target: "//modules:label_0_26", Aliases: []string{"//modules:label_0_26"}},
+			},
+			expectedErr: "alias '//modules:label' of '//modules:label' overlaps alias '//modules:label_0_26' of '//modules:label_0_26'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			table := module.NewAliasTable()
+			for _, m := range tt.modules {
+				table.Add(m, m.Target)
+			}
+
+			aliases, err := table.Resolve(tt.overrides)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			actualAliases := []string{}
+			for _, a := range aliases {
+				actualAliases = append(actualAliases, a.Alias)
+				if target, ok := tt.overrides[a.Alias]; ok {
+					assert.Equal(t, target, a.Target)
+				}
+			}
+			assert.Equal(t, tt.expectedAliases, actualAliases)
+		})
+	}
+}
+
+func TestColocateModules(t *testing.T) {
+	dir := t.TempDir()
+	label025 := filepath.Join(dir, "label_0_25")
+	label026 := filepath.Join(dir, "label_0_26")
+	root := filepath.Join(dir, "root")
+
+	for moduleDir, m := range map[string]*module.Metadata{
+		label025: {Target: "//third_party/terraform:label_0_25", Aliases: []string{"cloudposse/label/null"}},
+		label026: {Target: "//third_party/terraform:label_0_26", Aliases: []string{"cloudposse/label/null"}},
+	} {
+		require.NoError(t, os.MkdirAll(moduleDir, 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(moduleDir, "main.tf"), []byte(`variable "name" {}`), 0644))
+		require.NoError(t, m.Save(filepath.Join(moduleDir, ".please/terraform/module.json")))
+	}
+	require.NoError(t, os.MkdirAll(root, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "main.tf"), []byte(`module "label" {
+  source = "cloudposse/label/null"
+}
+`), 0644))
+
+	_, err := module.ColocateModules(".please/terraform/module.json", root, []string{label025, label026}, nil)
+	assert.ErrorContains(t, err, "is declared by both")
+
+	targets, err := module.ColocateModules(".please/terraform/module.json", root, []string{label025, label026}, map[string]string{
+		"cloudposse/label/null": "//third_party/terraform:label_0_26",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"//third_party/terraform:label_0_25", "//third_party/terraform:label_0_26"}, targets)
+
+	mainTf, err := os.ReadFile(filepath.Join(root, "main.tf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(mainTf), `source = "./`+filepath.Join(".modules", label026)+`"`)
+	assert.FileExists(t, filepath.Join(root, ".modules", label025, "main.tf"))
+	assert.FileExists(t, filepath.Join(root, ".modules", label026, "main.tf"))
+}
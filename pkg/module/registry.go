@@ -7,8 +7,10 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/VJftw/please-terraform/internal/logging"
 	"github.com/hashicorp/go-getter"
+
+	"github.com/VJftw/please-terraform/pkg/licence"
+	"github.com/VJftw/please-terraform/pkg/please"
 )
 
 // CommandRegistry represents the `module local` command and its flags.
@@ -25,7 +27,18 @@ type CommandRegistry struct {
 	Deps       []string `long:"deps" description:""`
 	Out        string   `long:"out" description:""`
 
+	Licences        []string `long:"licences" description:"The SPDX identifiers of the licences the module is declared to have. The detected licences must match."`
+	AllowedLicences []string `long:"allowed_licences" description:"The SPDX identifiers of the only licences which modules may have."`
+	DeniedLicences  []string `long:"denied_licences" description:"The SPDX identifiers of the licences which modules must not have."`
+
+	VendorDir   string `long:"vendor_dir" description:"A directory of modules vendored by 'please_terraform module vendor' to resolve the module from."`
+	ModuleCache string `long:"module_cache" env:"PLEASE_TERRAFORM_MODULE_CACHE" description:"A directory to resolve the module from, which downloaded modules are added to."`
+	Offline     bool   `long:"offline" description:"Fail if the module is not in the vendor directory or module cache instead of downloading it."`
+
+	HTTP *HTTPOpts
 	Opts *Opts
+
+	client *http.Client
 }
 
 // Execute builds a Terraform Registry Module as a Terraform Module.
@@ -33,14 +46,14 @@ func (c *CommandRegistry) Execute(args []string) error {
 	m := &Metadata{
 		Target:  fmt.Sprintf("//%s:%s", c.Pkg, c.Name),
 		Aliases: c.Aliases,
+		Version: c.Version,
 	}
 
-	downloadURL, err := c.GetDownloadURL()
-	if err != nil {
+	if err := c.fetch(); err != nil {
 		return err
 	}
 
-	if err := c.Download(downloadURL); err != nil {
+	if err := c.checkLicences(m); err != nil {
 		return err
 	}
 
@@ -62,9 +75,16 @@ func (c *CommandRegistry) Execute(args []string) error {
 	}
 
 	// colocate modules
-	if err := ColocateModules(c.Opts.MetadataFile, c.Out, c.Deps); err != nil {
+	depTargets, err := ColocateModules(c.Opts.MetadataFile, c.Out, c.Deps, nil)
+	if err != nil {
 		return err
 	}
+	// Upstream modules may call other registry modules which Terraform
+	// downloads itself, so these are not an error.
+	for _, problem := range UnresolvedSources(c.Out, "deps") {
+		log.Warn().Str("problem", problem).Msg("module is not in deps, so Terraform downloads it when initialising")
+	}
+	m.Deps = depTargets
 
 	if err := m.Save(filepath.Join(c.Out, c.Opts.MetadataFile)); err != nil {
 		return err
@@ -73,6 +93,147 @@ func (c *CommandRegistry) Execute(args []string) error {
 	return nil
 }
 
+// fetch resolves the module from the vendor directory or module cache,
+// downloading it from the registry if it is in neither and offline mode is
+// not enabled.
+func (c *CommandRegistry) fetch() error {
+	m := &RegistryModule{
+		Registry:  c.Registry,
+		Namespace: c.Namespace,
+		Name:      c.ModuleName,
+		Provider:  c.Provider,
+		Version:   c.Version,
+	}
+	modulePath, err := m.Path()
+	if err != nil {
+		return err
+	}
+
+	if c.VendorDir != "" {
+		src := filepath.Join(c.VendorDir, modulePath)
+		if _, err := os.Stat(src); err == nil {
+			if err := verifyVendored(c.VendorDir, src, m); err != nil {
+				return err
+			}
+			log.Info().Str("path", src).Msg("using vendored module")
+			return please.Sync(src, c.Out, []string{})
+		}
+	}
+
+	if c.ModuleCache != "" {
+		src := filepath.Join(c.ModuleCache, modulePath)
+		if _, err := os.Stat(src); err == nil {
+			log.Info().Str("path", src).Msg("using cached module")
+			return please.Sync(src, c.Out, []string{})
+		}
+	}
+
+	if c.Offline {
+		return fmt.Errorf(
+			"module %s is not in the vendor directory '%s' or module cache '%s' and offline mode is enabled, run 'please_terraform module vendor' to vendor it",
+			m, c.VendorDir, c.ModuleCache,
+		)
+	}
+
+	downloadURL, err := c.GetDownloadURL()
+	if err != nil {
+		return err
+	}
+
+	if err := c.Download(downloadURL); err != nil {
+		return err
+	}
+
+	if c.ModuleCache != "" {
+		if err := addToCache(c.Out, filepath.Join(c.ModuleCache, modulePath)); err != nil {
+			log.Warn().Err(err).Msg("could not add module to module cache")
+		}
+	}
+
+	return nil
+}
+
+// verifyVendored checks the given vendored module against the vendor
+// directory's lock manifest.
+func verifyVendored(vendorDir string, src string, m *RegistryModule) error {
+	lock, err := LoadLock(vendorDir)
+	if err != nil {
+		return err
+	}
+
+	locked := lock.Find(m)
+	if locked == nil {
+		return fmt.Errorf("vendored module %s is not in the lock manifest, run 'please_terraform module vendor' to vendor it", m)
+	}
+
+	hash, err := HashDir(src)
+	if err != nil {
+		return err
+	}
+	if hash != locked.Hash {
+		return fmt.Errorf("vendored module %s has hash '%s' but the lock manifest has '%s'", m, hash, locked.Hash)
+	}
+
+	return nil
+}
+
+// addToCache copies the given module into the module cache. The module is
+// copied into a temporary directory first so that concurrent builds never
+// see a partially copied module.
+func addToCache(src string, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dest), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := please.Sync(src, tmpDir, []string{}); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpDir, dest); err != nil {
+		// Another build may have cached the module first.
+		if _, statErr := os.Stat(dest); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	log.Debug().Str("path", dest).Msg("added module to module cache")
+
+	return nil
+}
+
+// checkLicences detects the licences of the downloaded module, records them
+// in the given Metadata and checks them against the declared, allowed and
+// denied licences.
+func (c *CommandRegistry) checkLicences(m *Metadata) error {
+	detected, err := licence.Detect(c.Out)
+	if err != nil {
+		return err
+	}
+	log.Info().Strs("licences", detected).Msg("detected licences")
+
+	m.Licences = detected
+	if len(m.Licences) == 0 {
+		m.Licences = []string{licence.NoAssertion}
+	}
+
+	policy := &licence.Policy{
+		Declared: c.Licences,
+		Allowed:  c.AllowedLicences,
+		Denied:   c.DeniedLicences,
+	}
+	if err := policy.Check(detected); err != nil {
+		return fmt.Errorf("%s/%s/%s %s: %w", c.Namespace, c.ModuleName, c.Provider, c.Version, err)
+	}
+
+	return nil
+}
+
 // GetDownloadURL returns the `hashicorp/go-getter` compatible URI.
 func (c *CommandRegistry) GetDownloadURL() (string, error) {
 	address := fmt.Sprintf("%s/v1/modules/%s/%s/%s/%s/download",
@@ -88,21 +249,51 @@ func (c *CommandRegistry) GetDownloadURL() (string, error) {
 		return "", fmt.Errorf("could not parse '%s' as URL: %w", address, err)
 	}
 
+	client, err := c.httpClient()
+	if err != nil {
+		return "", err
+	}
+
 	log.Info().Str("url", getterURL.String()).Msg("retrieving download url from registry")
-	resp, err := http.Get(getterURL.String())
+	resp, err := client.Get(getterURL.String())
 	if err != nil {
 		return "", fmt.Errorf("could not get download URL: %w", err)
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not get download URL from '%s': unexpected response status '%s'", getterURL, resp.Status)
+	}
 
 	downloadURL := resp.Header.Get("X-Terraform-Get")
+	if downloadURL == "" {
+		return "", fmt.Errorf("could not get download URL from '%s': response has no X-Terraform-Get header", getterURL)
+	}
 
 	return downloadURL, nil
 }
 
 // Download retrieves the configured Terraform Module from the configured Terraform Registry.
 func (c *CommandRegistry) Download(downloadURL string) error {
-	logging.Logger.Info().Str("url", downloadURL).Msg("downloading")
-	if err := getter.GetAny(c.Out, downloadURL); err != nil {
+	client, err := c.httpClient()
+	if err != nil {
+		return err
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("could not get current working directory: %w", err)
+	}
+
+	log.Info().Str("url", downloadURL).Msg("downloading")
+	getterClient := &getter.Client{
+		Src:     downloadURL,
+		Dst:     c.Out,
+		Pwd:     pwd,
+		Mode:    getter.ClientModeAny,
+		Getters: NewGetters(client),
+	}
+	if err := getterClient.Get(); err != nil {
 		return fmt.Errorf("could not get '%s': %w", downloadURL, err)
 	}
 
@@ -116,3 +307,17 @@ func (c *CommandRegistry) Download(downloadURL string) error {
 
 	return nil
 }
+
+// httpClient returns the HTTP client used to talk to the registry and to
+// download the module.
+func (c *CommandRegistry) httpClient() (*http.Client, error) {
+	if c.client == nil {
+		client, err := NewHTTPClient(c.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+
+	return c.client, nil
+}
@@ -0,0 +1,101 @@
+package module
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+)
+
+// CheckUnresolvedSources fails if any module block in the Terraform
+// configuration in the given directory still has a Please build label or
+// registry address as its source after colocation, i.e. refers to a module
+// which is missing from the given attribute of its build rule.
+func CheckUnresolvedSources(dir string, depsAttr string) error {
+	problems := UnresolvedSources(dir, depsAttr)
+	if len(problems) > 0 {
+		return fmt.Errorf("modules are not in %s:\n  %s", depsAttr, strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// UnresolvedSources returns a problem for each module block in the Terraform
+// configuration in the given directory which still has a Please build label
+// or registry address as its source after colocation.
+func UnresolvedSources(dir string, depsAttr string) []string {
+	cfg, err := tfconfig.LoadDir(dir)
+	if err != nil {
+		log.Debug().Err(err).Str("dir", dir).Msg("could not fully parse Terraform configuration")
+	}
+	if cfg == nil {
+		return nil
+	}
+
+	problems := []string{}
+	for _, mc := range cfg.ModuleCalls {
+		switch {
+		case tfconfig.IsPleaseSource(mc.Source):
+			problems = append(problems, fmt.Sprintf(
+				"module '%s' (%s) has source '%s', add '%s' to %s",
+				mc.Name, mc.DeclRange, mc.Source, sourceLabel(mc.Source), depsAttr,
+			))
+		case !tfconfig.IsLocalSource(mc.Source):
+			source, ok := tfconfig.ParseRegistrySource(mc.Source)
+			if !ok {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf(
+				"module '%s' (%s) has source '%s', add a terraform_registry_module for '%s' to %s",
+				mc.Name, mc.DeclRange, mc.Source, source.Address(), depsAttr,
+			))
+		}
+	}
+
+	return problems
+}
+
+// UnusedModules returns the targets of the given aliases' modules which no
+// module block in the Terraform configuration in the given directory uses.
+func UnusedModules(dir string, aliases []*Alias) []string {
+	cfg, err := tfconfig.LoadDir(dir)
+	if err != nil {
+		log.Debug().Err(err).Str("dir", dir).Msg("could not fully parse Terraform configuration")
+	}
+	if cfg == nil {
+		return []string{}
+	}
+
+	used := map[string]struct{}{}
+	for _, mc := range cfg.ModuleCalls {
+		if alias := matchAlias(mc.Source, aliases); alias != nil {
+			used[alias.Target] = struct{}{}
+		}
+	}
+
+	unused := map[string]struct{}{}
+	for _, alias := range aliases {
+		if _, ok := used[alias.Target]; !ok {
+			unused[alias.Target] = struct{}{}
+		}
+	}
+
+	targets := make([]string, 0, len(unused))
+	for target := range unused {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	return targets
+}
+
+// sourceLabel returns the build label of the given Please source, without
+// any subdirectory.
+func sourceLabel(source string) string {
+	if i := strings.Index(source[2:], "//"); i >= 0 {
+		return source[:i+2]
+	}
+
+	return source
+}
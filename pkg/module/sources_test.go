@@ -0,0 +1,79 @@
+package module_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUnresolvedSources(t *testing.T) {
+	var tests = []struct {
+		description string
+		config      string
+		expectedErr []string
+	}{
+		{
+			description: "resolved sources",
+			config: `module "label" {
+  source = "./.modules/plz-out/gen/third_party/terraform/label"
+}
+
+module "git" {
+  source = "git::https://example.com/vpc.git?ref=v1.2.0"
+}
+`,
+		},
+		{
+			description: "unresolved please label",
+			config: `module "label" {
+  source = "//third_party/terraform:label//exports"
+}
+`,
+			expectedErr: []string{"module 'label' (", "has source '//third_party/terraform:label//exports', add '//third_party/terraform:label' to modules"},
+		},
+		{
+			description: "unresolved registry address",
+			config: `module "label" {
+  source  = "cloudposse/label/null"
+  version = "0.25.0"
+}
+`,
+			expectedErr: []string{"add a terraform_registry_module for 'cloudposse/label/null' to modules"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(tt.config), 0644))
+
+			err := module.CheckUnresolvedSources(dir, "modules")
+			if len(tt.expectedErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			for _, expectedErr := range tt.expectedErr {
+				assert.ErrorContains(t, err, expectedErr)
+			}
+		})
+	}
+}
+
+func TestUnusedModules(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`module "exports" {
+  source = "cloudposse/label/null//exports"
+}
+`), 0644))
+
+	unused := module.UnusedModules(dir, []*module.Alias{
+		{Alias: "//third_party/terraform:label", Target: "//third_party/terraform:label"},
+		{Alias: "cloudposse/label/null", Target: "//third_party/terraform:label"},
+		{Alias: "//modules/vpc:vpc", Target: "//modules/vpc:vpc"},
+	})
+	assert.Equal(t, []string{"//modules/vpc:vpc"}, unused)
+}
@@ -0,0 +1,335 @@
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/buildtools/build"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+)
+
+const (
+	// DefaultRegistry is the registry of `terraform_registry_module`s which
+	// do not set one.
+	DefaultRegistry = "https://registry.terraform.io"
+	// LockFileName is the name of the lock manifest in a vendor directory.
+	LockFileName = "modules.lock.json"
+)
+
+// CommandVendor represents the `module vendor` command and its flags.
+type CommandVendor struct {
+	VendorDir      string   `long:"vendor_dir" default:"third_party/terraform/vendor" description:"The directory, relative to the repository root, to vendor modules into."`
+	BuildFileNames []string `long:"build_file_name" default:"BUILD" default:"BUILD.plz" description:"The names of Please BUILD files."`
+
+	HTTP *HTTPOpts
+}
+
+// Lock represents the lock manifest of a vendor directory.
+type Lock struct {
+	Modules []*LockedModule `json:"modules"`
+}
+
+// LockedModule represents a vendored registry module.
+type LockedModule struct {
+	Registry string `json:"registry"`
+	Module   string `json:"module"`
+	Version  string `json:"version"`
+	// Path is the module's directory relative to the vendor directory.
+	Path string `json:"path"`
+	// Hash is the `h1:` hash of the module's files.
+	Hash string `json:"hash"`
+}
+
+// RegistryModule represents a module in a Terraform registry.
+type RegistryModule struct {
+	Registry  string
+	Namespace string
+	Name      string
+	Provider  string
+	Version   string
+}
+
+// Execute vendors every module referenced by a `terraform_registry_module`
+// rule into the configured vendor directory.
+func (c *CommandVendor) Execute(args []string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("could not get current working directory: %w", err)
+	}
+	repoRoot, err := please.FindRepoRoot(cwd)
+	if err != nil {
+		return err
+	}
+
+	return c.Vendor(repoRoot)
+}
+
+// Vendor vendors every module referenced by a `terraform_registry_module`
+// rule in the given repository into the configured vendor directory.
+func (c *CommandVendor) Vendor(repoRoot string) error {
+	modules, err := FindRegistryModules(repoRoot, c.BuildFileNames)
+	if err != nil {
+		return err
+	}
+
+	vendorDir := filepath.Join(repoRoot, c.VendorDir)
+	lock, err := LoadLock(vendorDir)
+	if err != nil {
+		return err
+	}
+
+	client, err := NewHTTPClient(c.HTTP)
+	if err != nil {
+		return err
+	}
+
+	newLock := &Lock{Modules: []*LockedModule{}}
+	for _, m := range modules {
+		locked, err := vendorModule(client, vendorDir, lock, m)
+		if err != nil {
+			return err
+		}
+		newLock.Modules = append(newLock.Modules, locked)
+	}
+
+	if err := newLock.Save(vendorDir); err != nil {
+		return err
+	}
+
+	buildFile := filepath.Join(vendorDir, c.BuildFileNames[0])
+	if _, err := os.Stat(buildFile); errors.Is(err, os.ErrNotExist) {
+		// Registry modules depend on the whole vendor directory when they
+		// are configured to use it.
+		contents := `filegroup(
+    name = "vendor",
+    srcs = glob(["**"], exclude = ["` + c.BuildFileNames[0] + `"]),
+    visibility = ["PUBLIC"],
+)
+`
+		if err := os.WriteFile(buildFile, []byte(contents), 0644); err != nil {
+			return fmt.Errorf("could not write '%s': %w", buildFile, err)
+		}
+	}
+
+	log.Info().Str("path", vendorDir).Int("modules", len(newLock.Modules)).Msg("vendored modules")
+
+	return nil
+}
+
+func vendorModule(client *http.Client, vendorDir string, lock *Lock, m *RegistryModule) (*LockedModule, error) {
+	modulePath, err := m.Path()
+	if err != nil {
+		return nil, err
+	}
+	dest := filepath.Join(vendorDir, modulePath)
+	logger := log.With().Str("module", m.String()).Logger()
+
+	if locked := lock.Find(m); locked != nil {
+		hash, err := HashDir(dest)
+		if err == nil && hash == locked.Hash {
+			logger.Debug().Msg("module already vendored")
+			return locked, nil
+		}
+		logger.Warn().Msg("vendored module does not match the lock manifest, vendoring again")
+	}
+
+	if err := os.RemoveAll(dest); err != nil {
+		return nil, fmt.Errorf("could not remove '%s': %w", dest, err)
+	}
+
+	c := &CommandRegistry{
+		Registry:   m.Registry,
+		Namespace:  m.Namespace,
+		ModuleName: m.Name,
+		Provider:   m.Provider,
+		Version:    m.Version,
+		Out:        dest,
+		client:     client,
+	}
+	downloadURL, err := c.GetDownloadURL()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Download(downloadURL); err != nil {
+		return nil, err
+	}
+
+	hash, err := HashDir(dest)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info().Str("hash", hash).Msg("vendored module")
+
+	return &LockedModule{
+		Registry: m.Registry,
+		Module:   fmt.Sprintf("%s/%s/%s", m.Namespace, m.Name, m.Provider),
+		Version:  m.Version,
+		Path:     filepath.ToSlash(modulePath),
+		Hash:     hash,
+	}, nil
+}
+
+// FindRegistryModules returns the unique modules referenced by the
+// `terraform_registry_module` rules in the given repository.
+func FindRegistryModules(repoRoot string, buildFileNames []string) ([]*RegistryModule, error) {
+	modules := map[string]*RegistryModule{}
+	err := please.WalkBuildFiles(repoRoot, buildFileNames, func(pkg string, f *build.File) error {
+		for _, r := range f.Rules("terraform_registry_module") {
+			parts := strings.Split(r.AttrString("module"), "/")
+			if len(parts) != 3 || r.AttrString("version") == "" {
+				log.Warn().Str("pkg", pkg).Str("name", r.Name()).Msg("could not determine the module and version of terraform_registry_module, skipping")
+				continue
+			}
+
+			m := &RegistryModule{
+				Registry:  r.AttrString("registry"),
+				Namespace: parts[0],
+				Name:      parts[1],
+				Provider:  parts[2],
+				Version:   r.AttrString("version"),
+			}
+			if m.Registry == "" {
+				m.Registry = DefaultRegistry
+			}
+			modules[m.String()] = m
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(modules))
+	for key := range modules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*RegistryModule, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, modules[key])
+	}
+
+	return sorted, nil
+}
+
+func (m *RegistryModule) String() string {
+	return fmt.Sprintf("%s/%s/%s/%s@%s", m.Registry, m.Namespace, m.Name, m.Provider, m.Version)
+}
+
+// Path returns the directory of the module relative to a vendor directory
+// or module cache, i.e. `<registry host>/<namespace>/<name>/<provider>/<version>`.
+func (m *RegistryModule) Path() (string, error) {
+	registryURL, err := url.Parse(m.Registry)
+	if err != nil {
+		return "", fmt.Errorf("could not parse registry '%s' as URL: %w", m.Registry, err)
+	}
+
+	return filepath.Join(registryURL.Host, m.Namespace, m.Name, m.Provider, m.Version), nil
+}
+
+// LoadLock returns the lock manifest of the given vendor directory, which is
+// empty if the directory has not been vendored into yet.
+func LoadLock(vendorDir string) (*Lock, error) {
+	path := filepath.Join(vendorDir, LockFileName)
+	fileBytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Lock{Modules: []*LockedModule{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read lock manifest: %w", err)
+	}
+
+	lock := &Lock{}
+	if err := json.Unmarshal(fileBytes, lock); err != nil {
+		return nil, fmt.Errorf("could not unmarshal lock manifest '%s': %w", path, err)
+	}
+
+	return lock, nil
+}
+
+// Save writes the lock manifest into the given vendor directory.
+func (l *Lock) Save(vendorDir string) error {
+	fileBytes, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal lock manifest: %w", err)
+	}
+
+	if err := os.MkdirAll(vendorDir, 0750); err != nil {
+		return fmt.Errorf("could not create directory '%s': %w", vendorDir, err)
+	}
+
+	path := filepath.Join(vendorDir, LockFileName)
+	if err := os.WriteFile(path, append(fileBytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// Find returns the locked module for the given module, if any.
+func (l *Lock) Find(m *RegistryModule) *LockedModule {
+	module := fmt.Sprintf("%s/%s/%s", m.Namespace, m.Name, m.Provider)
+	for _, locked := range l.Modules {
+		if locked.Registry == m.Registry && locked.Module == module && locked.Version == m.Version {
+			return locked
+		}
+	}
+
+	return nil
+}
+
+// HashDir returns the `h1:` hash of the files in the given directory, as used
+// by Go modules, i.e. the base64 encoded SHA-256 of the SHA-256 and path of
+// every file.
+func HashDir(dir string) (string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			relPath, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(relPath))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not hash '%s': %w", dir, err)
+	}
+	sort.Strings(files)
+
+	summary := sha256.New()
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			return "", err
+		}
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("could not hash '%s': %w", file, err)
+		}
+		fmt.Fprintf(summary, "%x  %s\n", fileHash.Sum(nil), file)
+	}
+
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
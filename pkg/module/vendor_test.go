@@ -0,0 +1,168 @@
+package module_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRegistry returns a fake Terraform registry which serves a single module
+// version as a tarball.
+func newRegistry(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/modules/cloudposse/label/null/0.25.0/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Terraform-Get", server.URL+"/label.tar.gz")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/label.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		gz := gzip.NewWriter(w)
+		tw := tar.NewWriter(gz)
+		for name, contents := range map[string]string{
+			"main.tf": `variable "name" {}`,
+			"LICENSE": "SPDX-License-Identifier: Apache-2.0",
+		} {
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))}))
+			_, err := tw.Write([]byte(contents))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		require.NoError(t, gz.Close())
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestVendorAndOffline(t *testing.T) {
+	registry := newRegistry(t)
+
+	repo := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(repo, "third_party/terraform"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "third_party/terraform/BUILD"), []byte(`
+terraform_registry_module(
+    name = "label",
+    module = "cloudposse/label/null",
+    registry = "`+registry.URL+`",
+    version = "0.25.0",
+)
+
+terraform_registry_module(
+    name = "label_again",
+    module = "cloudposse/label/null",
+    registry = "`+registry.URL+`",
+    version = "0.25.0",
+)
+`), 0644))
+
+	c := &module.CommandVendor{VendorDir: "vendor", BuildFileNames: []string{"BUILD"}}
+	require.NoError(t, c.Vendor(repo))
+
+	vendorDir := filepath.Join(repo, "vendor")
+	lock, err := module.LoadLock(vendorDir)
+	require.NoError(t, err)
+	require.Len(t, lock.Modules, 1)
+	modulePath := filepath.Join(registry.Listener.Addr().String(), "cloudposse/label/null/0.25.0")
+	assert.Equal(t, registry.URL, lock.Modules[0].Registry)
+	assert.Equal(t, "cloudposse/label/null", lock.Modules[0].Module)
+	assert.Equal(t, filepath.ToSlash(modulePath), lock.Modules[0].Path)
+	assert.FileExists(t, filepath.Join(vendorDir, modulePath, "main.tf"))
+	assert.FileExists(t, filepath.Join(vendorDir, "BUILD"))
+
+	hash, err := module.HashDir(filepath.Join(vendorDir, modulePath))
+	require.NoError(t, err)
+	assert.Equal(t, hash, lock.Modules[0].Hash)
+
+	// The registry is unreachable in offline mode.
+	registry.Close()
+
+	newRegistryCommand := func(version string) *module.CommandRegistry {
+		return &module.CommandRegistry{
+			Name:       "label",
+			Pkg:        "third_party/terraform",
+			Registry:   registry.URL,
+			Namespace:  "cloudposse",
+			ModuleName: "label",
+			Provider:   "null",
+			Version:    version,
+			Out:        filepath.Join(t.TempDir(), "label"),
+			VendorDir:  vendorDir,
+			Offline:    true,
+			Opts:       &module.Opts{MetadataFile: ".please/terraform/module.json"},
+		}
+	}
+
+	cmdRegistry := newRegistryCommand("0.25.0")
+	require.NoError(t, cmdRegistry.Execute(nil))
+	assert.FileExists(t, filepath.Join(cmdRegistry.Out, "main.tf"))
+
+	assert.ErrorContains(t, newRegistryCommand("0.26.0").Execute(nil), "offline mode is enabled")
+
+	require.NoError(t, os.WriteFile(filepath.Join(vendorDir, modulePath, "main.tf"), []byte("# changed"), 0644))
+	assert.ErrorContains(t, newRegistryCommand("0.25.0").Execute(nil), "but the lock manifest has")
+}
+
+func TestModuleCache(t *testing.T) {
+	registry := newRegistry(t)
+	cacheDir := t.TempDir()
+
+	c := &module.CommandRegistry{
+		Name:        "label",
+		Pkg:         "third_party/terraform",
+		Registry:    registry.URL,
+		Namespace:   "cloudposse",
+		ModuleName:  "label",
+		Provider:    "null",
+		Version:     "0.25.0",
+		Out:         filepath.Join(t.TempDir(), "label"),
+		ModuleCache: cacheDir,
+		Opts:        &module.Opts{MetadataFile: ".please/terraform/module.json"},
+	}
+	require.NoError(t, c.Execute(nil))
+	assert.FileExists(t, filepath.Join(cacheDir, registry.Listener.Addr().String(), "cloudposse/label/null/0.25.0/main.tf"))
+
+	registry.Close()
+	c.Out = filepath.Join(t.TempDir(), "label")
+	c.Offline = true
+	require.NoError(t, c.Execute(nil))
+	assert.FileExists(t, filepath.Join(c.Out, "main.tf"))
+}
+
+func TestRegistryNestedRegistrySource(t *testing.T) {
+	cacheDir := t.TempDir()
+	modulePath := filepath.Join(cacheDir, "registry.example.com/cloudposse/label/null/0.25.0")
+	require.NoError(t, os.MkdirAll(modulePath, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(modulePath, "main.tf"), []byte(`
+module "this" {
+  source  = "cloudposse/label/null"
+  version = "0.24.1"
+}
+`), 0644))
+
+	c := &module.CommandRegistry{
+		Name:        "label",
+		Pkg:         "third_party/terraform",
+		Registry:    "https://registry.example.com",
+		Namespace:   "cloudposse",
+		ModuleName:  "label",
+		Provider:    "null",
+		Version:     "0.25.0",
+		Out:         filepath.Join(t.TempDir(), "label"),
+		ModuleCache: cacheDir,
+		Offline:     true,
+		Opts:        &module.Opts{MetadataFile: ".please/terraform/module.json"},
+	}
+	// The nested registry module is downloaded by Terraform, so it does not
+	// fail the build.
+	require.NoError(t, c.Execute(nil))
+	assert.FileExists(t, filepath.Join(c.Out, "main.tf"))
+}
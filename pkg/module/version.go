@@ -0,0 +1,140 @@
+package module
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+)
+
+// CheckModuleVersions checks the `version` constraints of the module calls in
+// the Terraform configuration in the given directory against the versions of
+// the modules which their sources are aliases of. The `version` arguments are
+// then removed as Terraform does not allow them for the local paths which
+// aliases are replaced with.
+func CheckModuleVersions(dir string, aliases []*Alias) error {
+	cfg, err := tfconfig.LoadDir(dir)
+	if err != nil {
+		log.Debug().Err(err).Str("dir", dir).Msg("could not fully parse Terraform configuration")
+	}
+	if cfg == nil {
+		return nil
+	}
+
+	removals := map[string][]hcl.Range{}
+	for _, mc := range cfg.ModuleCalls {
+		if mc.VersionRange == nil {
+			continue
+		}
+		alias := matchAlias(mc.Source, aliases)
+		if alias == nil {
+			continue
+		}
+
+		if err := checkModuleVersion(mc, alias); err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(mc.VersionRange.Filename, ".json") {
+			log.Warn().Str("module", mc.Name).Str("file", mc.VersionRange.Filename).Msg("cannot remove version argument from JSON configuration")
+			continue
+		}
+		removals[mc.VersionRange.Filename] = append(removals[mc.VersionRange.Filename], *mc.VersionRange)
+	}
+
+	for filename, ranges := range removals {
+		if err := removeRanges(filename, ranges); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matchAlias returns the alias which the given module source refers to, if
+// any. Sources may refer to a subdirectory of an alias.
+func matchAlias(source string, aliases []*Alias) *Alias {
+	// aliases are sorted longest first.
+	for _, alias := range aliases {
+		if source == alias.Alias || strings.HasPrefix(source, alias.Alias+"//") {
+			return alias
+		}
+	}
+
+	return nil
+}
+
+func checkModuleVersion(mc *tfconfig.ModuleCall, alias *Alias) error {
+	if alias.Version == "" {
+		log.Warn().
+			Str("module", mc.Name).
+			Str("target", alias.Target).
+			Msg("module has a version argument but refers to a module without a version, ignoring it")
+		return nil
+	}
+
+	constraints, err := version.NewConstraint(mc.Version)
+	if err != nil {
+		return fmt.Errorf("module '%s' (%s) has invalid version constraint '%s': %w", mc.Name, mc.DeclRange, mc.Version, err)
+	}
+	v, err := version.NewVersion(alias.Version)
+	if err != nil {
+		return fmt.Errorf("module '%s' has invalid version '%s': %w", alias.Target, alias.Version, err)
+	}
+
+	if !constraints.Check(v) {
+		return fmt.Errorf(
+			"module '%s' (%s) requires '%s' version '%s' but '%s' is version '%s'",
+			mc.Name, mc.DeclRange, alias.Alias, mc.Version, alias.Target, alias.Version,
+		)
+	}
+
+	return nil
+}
+
+// removeRanges removes the given ranges from the given file, along with the
+// lines which only contained them.
+func removeRanges(filename string, ranges []hcl.Range) error {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("could not read '%s': %w", filename, err)
+	}
+
+	// Remove from the end of the file so earlier offsets stay valid.
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start.Byte > ranges[j].Start.Byte })
+	for _, r := range ranges {
+		start, end := r.Start.Byte, r.End.Byte
+
+		lineStart := start
+		for lineStart > 0 && (src[lineStart-1] == ' ' || src[lineStart-1] == '\t') {
+			lineStart--
+		}
+		lineEnd := end
+		for lineEnd < len(src) && (src[lineEnd] == ' ' || src[lineEnd] == '\t') {
+			lineEnd++
+		}
+		if (lineStart == 0 || src[lineStart-1] == '\n') && (lineEnd == len(src) || src[lineEnd] == '\n' || src[lineEnd] == '\r') {
+			start = lineStart
+			end = lineEnd
+			if end < len(src) && src[end] == '\r' {
+				end++
+			}
+			if end < len(src) && src[end] == '\n' {
+				end++
+			}
+		}
+
+		src = append(src[:start:start], src[end:]...)
+	}
+
+	if err := os.WriteFile(filename, src, 0644); err != nil {
+		return fmt.Errorf("could not write '%s': %w", filename, err)
+	}
+
+	return nil
+}
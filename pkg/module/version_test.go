@@ -0,0 +1,100 @@
+package module_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckModuleVersions(t *testing.T) {
+	aliases := []*module.Alias{
+		{Alias: "//third_party/terraform:label", Target: "//third_party/terraform:label", Version: "0.25.0"},
+		{Alias: "cloudposse/label/null", Target: "//third_party/terraform:label", Version: "0.25.0"},
+		{Alias: "//modules/local", Target: "//modules/local:local"},
+	}
+
+	var tests = []struct {
+		description string
+		config      string
+		expected    string
+		expectedErr string
+	}{
+		{
+			description: "removes matching versions",
+			config: `module "label" {
+  source  = "cloudposse/label/null"
+  version = "0.25.0"
+  name    = "label"
+}
+
+module "exports" {
+  source  = "cloudposse/label/null//exports"
+  version = "~> 0.25"
+}
+`,
+			expected: `module "label" {
+  source  = "cloudposse/label/null"
+  name    = "label"
+}
+
+module "exports" {
+  source  = "cloudposse/label/null//exports"
+}
+`,
+		},
+		{
+			description: "ignores modules which are not aliases",
+			config: `module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+`,
+			expected: `module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+`,
+		},
+		{
+			description: "fails on mismatched versions",
+			config: `module "label" {
+  source  = "cloudposse/label/null"
+  version = ">= 0.26.0"
+}
+`,
+			expectedErr: "/main.tf:1,1-15) requires 'cloudposse/label/null' version '>= 0.26.0' but '//third_party/terraform:label' is version '0.25.0'",
+		},
+		{
+			description: "fails on invalid constraints",
+			config: `module "label" {
+  source  = "cloudposse/label/null"
+  version = "latest"
+}
+`,
+			expectedErr: "has invalid version constraint 'latest'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			dir := t.TempDir()
+			mainTf := filepath.Join(dir, "main.tf")
+			require.NoError(t, os.WriteFile(mainTf, []byte(tt.config), 0644))
+
+			err := module.CheckModuleVersions(dir, aliases)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			actual, err := os.ReadFile(mainTf)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(actual))
+		})
+	}
+}
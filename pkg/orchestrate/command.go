@@ -0,0 +1,185 @@
+package orchestrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/VJftw/please-terraform/internal/logging"
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/root"
+)
+
+var log = &logging.Logger
+
+// Command represents the `run` command and its flags.
+type Command struct {
+	Jobs int      `long:"jobs" short:"j" default:"4" description:"The maximum number of Terraform roots to run in parallel."`
+	Plz  string   `long:"plz" default:"plz" description:"The Please binary to run workflows with."`
+	Args []string `long:"args" short:"a" description:"Arguments to pass to the workflow of every Terraform root."`
+
+	Positional struct {
+		Workflow string   `positional-arg-name:"workflow" description:"The workflow to run, e.g. 'plan' to run the '<root>_plan' targets."`
+		Targets  []string `positional-arg-name:"targets" description:"The Please targets to find Terraform roots in, e.g. '//infra/...'."`
+	} `positional-args:"yes" required:"yes"`
+
+	PleaseOpts *please.Opts
+	RootOpts   *root.Opts
+}
+
+// Execute runs the configured workflow on every Terraform root in the
+// configured targets, respecting the dependencies between them.
+func (c *Command) Execute(args []string) error {
+	plz := &please.CLI{Binary: c.Plz}
+
+	queryArgs := append([]string{"query", "alltargets", "--include", "terraform_root"}, c.Positional.Targets...)
+	labels, err := plz.Lines(queryArgs...)
+	if err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		return fmt.Errorf("no Terraform roots found in %s", strings.Join(c.Positional.Targets, " "))
+	}
+	log.Info().Strs("roots", labels).Msg("found roots")
+
+	roots, err := c.loadRoots(plz, labels)
+	if err != nil {
+		return err
+	}
+
+	graph, err := NewGraph(roots)
+	if err != nil {
+		return err
+	}
+
+	workflowTargets := make([]string, 0, len(labels))
+	for _, label := range labels {
+		workflowTargets = append(workflowTargets, WorkflowTarget(label, c.Positional.Workflow))
+	}
+	// Build every workflow up front so that the parallel `plz run`s below
+	// do not contend for the Please lock whilst building.
+	if err := plz.Command(append([]string{"build"}, workflowTargets...)...).Run(); err != nil {
+		return fmt.Errorf("could not build workflows: %w", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	results := graph.Run(ctx, c.Jobs, func(ctx context.Context, label string) error {
+		runArgs := append([]string{"run", WorkflowTarget(label, c.Positional.Workflow), "--"}, c.Args...)
+		stdout := newPrefixWriter(os.Stdout, label)
+		stderr := newPrefixWriter(os.Stderr, label)
+		defer stdout.Flush()
+		defer stderr.Flush()
+
+		cmd := plz.Command(runArgs...)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		exited := make(chan struct{})
+		defer close(exited)
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = cmd.Process.Signal(os.Interrupt)
+			case <-exited:
+			}
+		}()
+
+		return cmd.Wait()
+	})
+
+	if err := WriteResults(os.Stdout, results); err != nil {
+		return err
+	}
+
+	unsuccessful := 0
+	for _, result := range results {
+		if result.Status != StatusSucceeded {
+			unsuccessful++
+		}
+	}
+	if unsuccessful > 0 {
+		return fmt.Errorf("%d of %d roots did not succeed", unsuccessful, len(results))
+	}
+
+	return nil
+}
+
+func (c *Command) loadRoots(plz *please.CLI, labels []string) (map[string]*root.Metadata, error) {
+	repoRoot, err := plz.Lines("query", "reporoot")
+	if err != nil {
+		return nil, err
+	}
+
+	outputTargets := make([]string, 0, len(labels))
+	for _, label := range labels {
+		outputTarget, err := RootOutputTarget(label)
+		if err != nil {
+			return nil, err
+		}
+		outputTargets = append(outputTargets, outputTarget.String())
+	}
+	if err := plz.Command(append([]string{"build"}, outputTargets...)...).Run(); err != nil {
+		return nil, fmt.Errorf("could not build roots: %w", err)
+	}
+
+	roots := map[string]*root.Metadata{}
+	for i, label := range labels {
+		outputTarget, _ := please.ParseLabel(outputTargets[i])
+		metadataPath := filepath.Join(
+			repoRoot[0], c.PleaseOpts.PlzOutDir, "gen",
+			outputTarget.Pkg, strings.TrimPrefix(outputTarget.Name, "_"),
+			c.RootOpts.MetadataFile,
+		)
+		m, err := root.LoadMetadata(metadataPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not load metadata for '%s': %w", label, err)
+		}
+		roots[label] = m
+	}
+
+	return roots, nil
+}
+
+// RootOutputTarget returns the target which builds the Terraform root
+// configuration for the given `terraform_root` label.
+func RootOutputTarget(label string) (*please.Label, error) {
+	l, err := please.ParseLabel(label)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.WithName(fmt.Sprintf("_%s_root", l.Name)), nil
+}
+
+// WorkflowTarget returns the target which runs the given workflow for the
+// given `terraform_root` label.
+func WorkflowTarget(label string, workflow string) string {
+	return fmt.Sprintf("%s_%s", label, workflow)
+}
+
+// WriteResults writes a table of the given results.
+func WriteResults(w io.Writer, results []*Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROOT\tSTATUS\tDURATION\tERROR")
+	for _, result := range results {
+		errMsg := ""
+		if result.Err != nil {
+			errMsg = result.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Label, result.Status, result.Duration.Round(time.Millisecond), errMsg)
+	}
+
+	return tw.Flush()
+}
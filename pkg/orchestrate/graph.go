@@ -0,0 +1,242 @@
+package orchestrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VJftw/please-terraform/pkg/root"
+)
+
+// Status represents the outcome of running a workflow on a Terraform root.
+type Status string
+
+const (
+	// StatusSucceeded indicates that the workflow succeeded.
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed indicates that the workflow failed.
+	StatusFailed Status = "failed"
+	// StatusSkipped indicates that the workflow was not run as a root it
+	// depends on did not succeed.
+	StatusSkipped Status = "skipped"
+	// StatusCancelled indicates that the workflow was not run as the run was
+	// cancelled, e.g. by an interrupt, before it started.
+	StatusCancelled Status = "cancelled"
+)
+
+// Result represents the outcome of running a workflow on a Terraform root.
+type Result struct {
+	Label    string
+	Status   Status
+	Err      error
+	Duration time.Duration
+}
+
+// RunFunc runs a workflow on the Terraform root with the given label.
+type RunFunc func(ctx context.Context, label string) error
+
+// Graph represents the order in which Terraform roots must be run.
+type Graph struct {
+	labels     []string
+	deps       map[string][]string
+	dependents map[string][]string
+}
+
+// NewGraph returns the Graph of the given Terraform roots keyed by their
+// label. A root depends on another root if it lists it in `depends_on_roots`
+// or if one of its `terraform_remote_state` data sources reads the other
+// root's backend. Dependencies on roots which are not given are ignored.
+func NewGraph(roots map[string]*root.Metadata) (*Graph, error) {
+	g := &Graph{
+		labels:     make([]string, 0, len(roots)),
+		deps:       map[string][]string{},
+		dependents: map[string][]string{},
+	}
+
+	for label := range roots {
+		g.labels = append(g.labels, label)
+	}
+	sort.Strings(g.labels)
+
+	for _, label := range g.labels {
+		m := roots[label]
+		deps := map[string]struct{}{}
+
+		for _, dep := range m.DependsOnRoots {
+			if _, ok := roots[dep]; !ok {
+				log.Debug().Str("root", label).Str("dependency", dep).Msg("ignoring dependency on root which is not being run")
+				continue
+			}
+			deps[dep] = struct{}{}
+		}
+
+		for _, rs := range m.RemoteStates {
+			for _, other := range g.labels {
+				if other != label && roots[other].Backend.Matches(rs.Backend) {
+					log.Debug().Str("root", label).Str("dependency", other).Str("remote_state", rs.Name).Msg("found remote state dependency")
+					deps[other] = struct{}{}
+				}
+			}
+		}
+
+		for dep := range deps {
+			if dep == label {
+				return nil, fmt.Errorf("'%s' depends on itself", label)
+			}
+			g.deps[label] = append(g.deps[label], dep)
+			g.dependents[dep] = append(g.dependents[dep], label)
+		}
+		sort.Strings(g.deps[label])
+	}
+	for _, dependents := range g.dependents {
+		sort.Strings(dependents)
+	}
+
+	if cycle := g.findCycle(); cycle != nil {
+		return nil, fmt.Errorf("found dependency cycle between roots: %s", strings.Join(cycle, " -> "))
+	}
+
+	return g, nil
+}
+
+// Dependencies returns the roots which the given root depends on.
+func (g *Graph) Dependencies(label string) []string {
+	return g.deps[label]
+}
+
+func (g *Graph) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	stack := []string{}
+
+	var visit func(label string) []string
+	visit = func(label string) []string {
+		state[label] = visiting
+		stack = append(stack, label)
+		for _, dep := range g.deps[label] {
+			switch state[dep] {
+			case visiting:
+				for i, l := range stack {
+					if l == dep {
+						return append(append([]string{}, stack[i:]...), dep)
+					}
+				}
+			case unvisited:
+				if cycle := visit(dep); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[label] = visited
+		return nil
+	}
+
+	for _, label := range g.labels {
+		if state[label] == unvisited {
+			if cycle := visit(label); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run runs the given function on every root, running at most `jobs` roots in
+// parallel. A root is only run once all of the roots it depends on have
+// succeeded, otherwise it is skipped. Once the given context is cancelled, no
+// more roots are started. Results are returned in label order.
+func (g *Graph) Run(ctx context.Context, jobs int, run RunFunc) []*Result {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	results := map[string]*Result{}
+	remaining := map[string]int{}
+	blockedBy := map[string]string{}
+	ready := []string{}
+	for _, label := range g.labels {
+		remaining[label] = len(g.deps[label])
+		if remaining[label] == 0 {
+			ready = append(ready, label)
+		}
+	}
+
+	done := make(chan *Result)
+	running := 0
+
+	var complete func(result *Result)
+	complete = func(result *Result) {
+		results[result.Label] = result
+		for _, dependent := range g.dependents[result.Label] {
+			if result.Status != StatusSucceeded {
+				if _, ok := blockedBy[dependent]; !ok {
+					blockedBy[dependent] = result.Label
+				}
+			}
+			remaining[dependent]--
+			if remaining[dependent] > 0 {
+				continue
+			}
+			if upstream, ok := blockedBy[dependent]; ok {
+				complete(&Result{
+					Label:  dependent,
+					Status: StatusSkipped,
+					Err:    fmt.Errorf("'%s' did not succeed", upstream),
+				})
+				continue
+			}
+			ready = append(ready, dependent)
+		}
+	}
+
+	for len(results) < len(g.labels) {
+		for len(ready) > 0 && running < jobs {
+			label := ready[0]
+			ready = ready[1:]
+			// Roots are not started after cancellation so that, for example,
+			// no new applies take state locks after an interrupt.
+			if err := ctx.Err(); err != nil {
+				complete(&Result{Label: label, Status: StatusCancelled, Err: err})
+				continue
+			}
+			running++
+			go func() {
+				start := time.Now()
+				log.Info().Str("root", label).Msg("running root")
+				err := run(ctx, label)
+				result := &Result{Label: label, Status: StatusSucceeded, Duration: time.Since(start)}
+				if err != nil {
+					result.Status = StatusFailed
+					result.Err = err
+				}
+				done <- result
+			}()
+		}
+
+		if running == 0 {
+			// Only reachable if there is a cycle, which NewGraph prevents.
+			break
+		}
+
+		result := <-done
+		running--
+		complete(result)
+	}
+
+	ordered := make([]*Result, 0, len(g.labels))
+	for _, label := range g.labels {
+		if result, ok := results[label]; ok {
+			ordered = append(ordered, result)
+		}
+	}
+
+	return ordered
+}
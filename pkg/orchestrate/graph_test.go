@@ -0,0 +1,174 @@
+package orchestrate_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/orchestrate"
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func s3Backend(key string) *tfconfig.Backend {
+	return &tfconfig.Backend{
+		Type: "s3",
+		Config: map[string]interface{}{
+			"bucket": "my-terraform-state",
+			"key":    key,
+		},
+	}
+}
+
+func TestNewGraph(t *testing.T) {
+	roots := map[string]*root.Metadata{
+		"//infra:network": {
+			Target:  "//infra:network",
+			Backend: s3Backend("infra/network.tfstate"),
+		},
+		"//infra:cluster": {
+			Target:  "//infra:cluster",
+			Backend: s3Backend("infra/cluster.tfstate"),
+			RemoteStates: []*tfconfig.RemoteState{
+				{Name: "network", Backend: s3Backend("infra/network.tfstate")},
+			},
+		},
+		"//app:app": {
+			Target:         "//app:app",
+			Backend:        s3Backend("app/app.tfstate"),
+			DependsOnRoots: []string{"//infra:cluster", "//not:selected"},
+		},
+	}
+
+	g, err := orchestrate.NewGraph(roots)
+	require.NoError(t, err)
+
+	assert.Empty(t, g.Dependencies("//infra:network"))
+	assert.Equal(t, []string{"//infra:network"}, g.Dependencies("//infra:cluster"))
+	assert.Equal(t, []string{"//infra:cluster"}, g.Dependencies("//app:app"))
+}
+
+func TestNewGraphSameBucket(t *testing.T) {
+	roots := map[string]*root.Metadata{
+		"//infra:network": {
+			Target:  "//infra:network",
+			Backend: s3Backend("infra/network.tfstate"),
+			RemoteStates: []*tfconfig.RemoteState{
+				// The key is not static, so it is not known which state is read.
+				{Name: "cluster", Backend: &tfconfig.Backend{
+					Type:   "s3",
+					Config: map[string]interface{}{"bucket": "my-terraform-state"},
+				}},
+			},
+		},
+		"//infra:cluster": {
+			Target:  "//infra:cluster",
+			Backend: s3Backend("infra/cluster.tfstate"),
+			RemoteStates: []*tfconfig.RemoteState{
+				{Name: "dns", Backend: s3Backend("infra/dns.tfstate")},
+			},
+		},
+	}
+
+	g, err := orchestrate.NewGraph(roots)
+	require.NoError(t, err)
+
+	assert.Empty(t, g.Dependencies("//infra:network"))
+	assert.Empty(t, g.Dependencies("//infra:cluster"))
+}
+
+func TestNewGraphCycle(t *testing.T) {
+	roots := map[string]*root.Metadata{
+		"//a:a": {Target: "//a:a", DependsOnRoots: []string{"//b:b"}},
+		"//b:b": {Target: "//b:b", DependsOnRoots: []string{"//a:a"}},
+	}
+
+	_, err := orchestrate.NewGraph(roots)
+	assert.ErrorContains(t, err, "cycle")
+}
+
+func TestGraphRun(t *testing.T) {
+	roots := map[string]*root.Metadata{
+		"//a:a": {Target: "//a:a"},
+		"//b:b": {Target: "//b:b", DependsOnRoots: []string{"//a:a"}},
+		"//c:c": {Target: "//c:c", DependsOnRoots: []string{"//b:b"}},
+		"//d:d": {Target: "//d:d"},
+		"//e:e": {Target: "//e:e", DependsOnRoots: []string{"//a:a", "//d:d"}},
+	}
+	g, err := orchestrate.NewGraph(roots)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	order := []string{}
+	results := g.Run(context.Background(), 2, func(ctx context.Context, label string) error {
+		mu.Lock()
+		order = append(order, label)
+		mu.Unlock()
+		if label == "//b:b" {
+			return errors.New("plan failed")
+		}
+		return nil
+	})
+
+	statuses := map[string]orchestrate.Status{}
+	for _, result := range results {
+		statuses[result.Label] = result.Status
+	}
+	assert.Equal(t, map[string]orchestrate.Status{
+		"//a:a": orchestrate.StatusSucceeded,
+		"//b:b": orchestrate.StatusFailed,
+		"//c:c": orchestrate.StatusSkipped,
+		"//d:d": orchestrate.StatusSucceeded,
+		"//e:e": orchestrate.StatusSucceeded,
+	}, statuses)
+
+	assert.NotContains(t, order, "//c:c")
+	assert.Less(t, indexOf(order, "//a:a"), indexOf(order, "//b:b"))
+	assert.Less(t, indexOf(order, "//a:a"), indexOf(order, "//e:e"))
+	assert.Less(t, indexOf(order, "//d:d"), indexOf(order, "//e:e"))
+}
+
+func TestGraphRunCancelled(t *testing.T) {
+	roots := map[string]*root.Metadata{
+		"//a:a": {Target: "//a:a"},
+		"//b:b": {Target: "//b:b"},
+		"//c:c": {Target: "//c:c"},
+		"//d:d": {Target: "//d:d", DependsOnRoots: []string{"//b:b"}},
+	}
+	g, err := orchestrate.NewGraph(roots)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	order := []string{}
+	results := g.Run(ctx, 1, func(ctx context.Context, label string) error {
+		order = append(order, label)
+		// An interrupt whilst the first root runs.
+		cancel()
+		return nil
+	})
+
+	statuses := map[string]orchestrate.Status{}
+	for _, result := range results {
+		statuses[result.Label] = result.Status
+	}
+	assert.Equal(t, map[string]orchestrate.Status{
+		"//a:a": orchestrate.StatusSucceeded,
+		"//b:b": orchestrate.StatusCancelled,
+		"//c:c": orchestrate.StatusCancelled,
+		"//d:d": orchestrate.StatusSkipped,
+	}, statuses)
+	assert.Equal(t, []string{"//a:a"}, order)
+}
+
+func indexOf(labels []string, label string) int {
+	for i, l := range labels {
+		if l == label {
+			return i
+		}
+	}
+	return -1
+}
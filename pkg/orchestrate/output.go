@@ -0,0 +1,56 @@
+package orchestrate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// outputMu serialises writes from roots running in parallel so that their
+// lines do not interleave.
+var outputMu sync.Mutex
+
+// prefixWriter prefixes every line written to it with a root's label.
+type prefixWriter struct {
+	out    io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(out io.Writer, label string) *prefixWriter {
+	return &prefixWriter{out: out, prefix: []byte(fmt.Sprintf("[%s] ", label))}
+}
+
+// Write implements io.Writer, only writing complete lines to the underlying
+// writer.
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+
+	_, err := w.out.Write(append(append([]byte{}, w.prefix...), line...))
+	return err
+}
+
+// Flush writes any incomplete line left in the buffer.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) == 0 {
+		return
+	}
+	_ = w.writeLine(append(w.buf, '\n'))
+	w.buf = nil
+}
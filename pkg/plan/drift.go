@@ -0,0 +1,56 @@
+package plan
+
+// DriftReport represents whether a Terraform root's real infrastructure has
+// drifted from its state.
+type DriftReport struct {
+	// Drifted is whether any resources were changed outside of Terraform.
+	Drifted bool `json:"drifted"`
+	// Drift contains the resources which were changed outside of Terraform.
+	Drift []*ResourceReport `json:"drift"`
+	// PendingChanges contains the resources which Terraform would change to
+	// match the configuration.
+	PendingChanges []*ResourceReport `json:"pending_changes"`
+}
+
+// ResourceReport represents a change to a single resource instance.
+type ResourceReport struct {
+	Address    string   `json:"address"`
+	Actions    []string `json:"actions"`
+	Attributes []string `json:"attributes"`
+}
+
+// NewDriftReport returns a DriftReport from a refresh-only plan and a normal
+// plan of the same Terraform root. The normal plan is optional.
+func NewDriftReport(refreshOnly *Plan, normal *Plan) *DriftReport {
+	r := &DriftReport{
+		Drift:          []*ResourceReport{},
+		PendingChanges: []*ResourceReport{},
+	}
+
+	for _, rc := range refreshOnly.ResourceDrift {
+		if rc.IsNoOp() {
+			continue
+		}
+		r.Drift = append(r.Drift, newResourceReport(rc))
+	}
+	r.Drifted = len(r.Drift) > 0
+
+	if normal != nil {
+		for _, rc := range normal.ResourceChanges {
+			if rc.IsNoOp() {
+				continue
+			}
+			r.PendingChanges = append(r.PendingChanges, newResourceReport(rc))
+		}
+	}
+
+	return r
+}
+
+func newResourceReport(rc *ResourceChange) *ResourceReport {
+	return &ResourceReport{
+		Address:    rc.Address,
+		Actions:    rc.Change.Actions,
+		Attributes: rc.ChangedAttributes(),
+	}
+}
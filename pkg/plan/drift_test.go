@@ -0,0 +1,56 @@
+package plan_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/plan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDriftReport(t *testing.T) {
+	var tests = []struct {
+		description string
+		refreshOnly string
+		expected    *plan.DriftReport
+	}{
+		{
+			"drifted",
+			"refresh_only_drifted.json",
+			&plan.DriftReport{
+				Drifted: true,
+				Drift: []*plan.ResourceReport{
+					{Address: "aws_s3_bucket.logs", Actions: []string{"update"}, Attributes: []string{"tags", "versioning"}},
+					{Address: "null_resource.removed", Actions: []string{"delete"}, Attributes: []string{"id"}},
+				},
+				PendingChanges: []*plan.ResourceReport{
+					{Address: "module.label.null_resource.version", Actions: []string{"create"}, Attributes: []string{"triggers"}},
+				},
+			},
+		},
+		{
+			"clean",
+			"refresh_only_clean.json",
+			&plan.DriftReport{
+				Drifted: false,
+				Drift:   []*plan.ResourceReport{},
+				PendingChanges: []*plan.ResourceReport{
+					{Address: "module.label.null_resource.version", Actions: []string{"create"}, Attributes: []string{"triggers"}},
+				},
+			},
+		},
+	}
+
+	normal, err := plan.Load(filepath.Join("testdata", "plan.json"))
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			refreshOnly, err := plan.Load(filepath.Join("testdata", tt.refreshOnly))
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, plan.NewDriftReport(refreshOnly, normal))
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Package plan parses Terraform plans in the JSON format produced by
+// `terraform show -json <plan file>`.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Plan represents a Terraform plan.
+type Plan struct {
+	FormatVersion    string            `json:"format_version"`
+	TerraformVersion string            `json:"terraform_version"`
+	ResourceDrift    []*ResourceChange `json:"resource_drift"`
+	ResourceChanges  []*ResourceChange `json:"resource_changes"`
+}
+
+// ResourceChange represents a planned change, or a detected drift, of a
+// resource instance.
+type ResourceChange struct {
+	Address       string  `json:"address"`
+	ModuleAddress string  `json:"module_address,omitempty"`
+	Mode          string  `json:"mode"`
+	Type          string  `json:"type"`
+	Name          string  `json:"name"`
+	ProviderName  string  `json:"provider_name"`
+	Change        *Change `json:"change"`
+}
+
+// Change represents the before and after states of a resource instance.
+type Change struct {
+	Actions []string    `json:"actions"`
+	Before  interface{} `json:"before"`
+	After   interface{} `json:"after"`
+}
+
+// Load returns the Plan loaded from the given JSON file.
+func Load(path string) (*Plan, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read plan '%s': %w", path, err)
+	}
+
+	return Parse(fileBytes)
+}
+
+// Parse returns the Plan parsed from the given JSON.
+func Parse(planBytes []byte) (*Plan, error) {
+	p := &Plan{}
+	if err := json.Unmarshal(planBytes, p); err != nil {
+		return nil, fmt.Errorf("could not unmarshal plan: %w", err)
+	}
+
+	return p, nil
+}
+
+// IsNoOp returns whether the change does nothing.
+func (c *ResourceChange) IsNoOp() bool {
+	if c.Change == nil {
+		return true
+	}
+	for _, action := range c.Change.Actions {
+		if action != "no-op" && action != "read" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ChangedAttributes returns the sorted top-level attributes which differ
+// between the before and after states of the change.
+func (c *ResourceChange) ChangedAttributes() []string {
+	if c.Change == nil {
+		return []string{}
+	}
+
+	before, _ := c.Change.Before.(map[string]interface{})
+	after, _ := c.Change.After.(map[string]interface{})
+
+	keys := map[string]struct{}{}
+	for key := range before {
+		keys[key] = struct{}{}
+	}
+	for key := range after {
+		keys[key] = struct{}{}
+	}
+
+	changed := []string{}
+	for key := range keys {
+		beforeJSON, _ := json.Marshal(before[key])
+		afterJSON, _ := json.Marshal(after[key])
+		if string(beforeJSON) != string(afterJSON) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed
+}
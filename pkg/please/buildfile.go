@@ -0,0 +1,66 @@
+package please
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// SkipDirs are directories which are never searched for BUILD files or
+// Terraform configuration, as they are not part of the repository's sources.
+var SkipDirs = map[string]struct{}{
+	".git":       {},
+	".terraform": {},
+	".please":    {},
+	"plz-out":    {},
+}
+
+// WalkBuildFiles parses every BUILD file in the given repository and calls fn
+// with each one's package. BUILD files which cannot be parsed, e.g. as they
+// use syntax specific to Please, are skipped with a warning.
+func WalkBuildFiles(repoRoot string, buildFileNames []string, fn func(pkg string, f *build.File) error) error {
+	isBuildFile := map[string]struct{}{}
+	for _, name := range buildFileNames {
+		isBuildFile[name] = struct{}{}
+	}
+
+	err := filepath.WalkDir(repoRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if _, ok := SkipDirs[d.Name()]; ok {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := isBuildFile[d.Name()]; !ok {
+			return nil
+		}
+
+		relDir, err := filepath.Rel(repoRoot, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		f, err := build.ParseBuild(p, data)
+		if err != nil {
+			log.Warn().Err(err).Str("path", p).Msg("could not parse BUILD file, skipping")
+			return nil
+		}
+
+		return fn(filepath.ToSlash(relDir), f)
+	})
+	if err != nil {
+		return fmt.Errorf("could not find BUILD files in '%s': %w", repoRoot, err)
+	}
+
+	return nil
+}
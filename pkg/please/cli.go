@@ -0,0 +1,43 @@
+package please
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// CLI invokes the Please binary.
+type CLI struct {
+	Binary string
+}
+
+// Command returns a command which invokes Please with the given arguments.
+func (c *CLI) Command(args ...string) *exec.Cmd {
+	cmd := exec.Command(c.Binary, args...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// Lines invokes Please with the given arguments and returns the non-empty
+// lines it writes to stdout.
+func (c *CLI) Lines(args ...string) ([]string, error) {
+	cmd := c.Command(args...)
+	stdout := &bytes.Buffer{}
+	cmd.Stdout = stdout
+
+	log.Debug().Strs("args", args).Msg("running please")
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("could not run '%s %s': %w", c.Binary, strings.Join(args, " "), err)
+	}
+
+	lines := []string{}
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
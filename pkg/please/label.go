@@ -0,0 +1,41 @@
+package please
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Label represents a canonical Please build label, e.g. `//pkg:name`.
+type Label struct {
+	Pkg  string
+	Name string
+}
+
+// ParseLabel parses the given canonical or package-default Please build label.
+func ParseLabel(s string) (*Label, error) {
+	if !strings.HasPrefix(s, "//") {
+		return nil, fmt.Errorf("'%s' is not an absolute build label", s)
+	}
+
+	pkgName := strings.TrimPrefix(s, "//")
+	pkg, name, found := strings.Cut(pkgName, ":")
+	if !found {
+		name = path.Base(pkg)
+	}
+	if name == "" || name == "." {
+		return nil, fmt.Errorf("'%s' does not have a target name", s)
+	}
+
+	return &Label{Pkg: pkg, Name: name}, nil
+}
+
+// String returns the canonical form of the label.
+func (l *Label) String() string {
+	return fmt.Sprintf("//%s:%s", l.Pkg, l.Name)
+}
+
+// WithName returns a label in the same package with the given name.
+func (l *Label) WithName(name string) *Label {
+	return &Label{Pkg: l.Pkg, Name: name}
+}
@@ -0,0 +1,34 @@
+package please_test
+
+import (
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLabel(t *testing.T) {
+	var tests = []struct {
+		in          string
+		expected    *please.Label
+		expectedErr bool
+	}{
+		{"//pkg:name", &please.Label{Pkg: "pkg", Name: "name"}, false},
+		{"//pkg/sub:name", &please.Label{Pkg: "pkg/sub", Name: "name"}, false},
+		{"//pkg/sub", &please.Label{Pkg: "pkg/sub", Name: "sub"}, false},
+		{":name", nil, true},
+		{"//pkg:", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			label, err := please.ParseLabel(tt.in)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, label)
+		})
+	}
+}
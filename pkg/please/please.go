@@ -9,7 +9,7 @@ import (
 	"github.com/VJftw/please-terraform/internal/logging"
 )
 
-var log = logging.NewLogger()
+var log = &logging.Logger
 
 // Opts represents the available options to this Please package as a whole.
 type Opts struct {
@@ -34,3 +34,16 @@ func MustRepoRoot(plzOutDir string) string {
 		Msg("resolved repo root path")
 	return repoRoot
 }
+
+// FindRepoRoot returns the closest parent directory of the given directory,
+// including itself, which contains a `.plzconfig` file.
+func FindRepoRoot(dir string) (string, error) {
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, ".plzconfig")); err == nil {
+			return current, nil
+		}
+		if parent := filepath.Dir(current); parent == current {
+			return "", fmt.Errorf("could not find a .plzconfig in '%s' or any of its parents", dir)
+		}
+	}
+}
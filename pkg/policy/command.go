@@ -0,0 +1,77 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/VJftw/please-terraform/internal/logging"
+	"github.com/VJftw/please-terraform/pkg/plan"
+)
+
+var log = &logging.Logger
+
+// Command represents the `policy` command and available subcommands.
+type Command struct {
+	Check *CommandCheck `command:"check"`
+}
+
+// CommandCheck represents the `policy check` command and its flags.
+type CommandCheck struct {
+	Plan      string `long:"plan" required:"true" description:"The Terraform plan in JSON format, as produced by 'terraform show -json <plan file>'."`
+	Namespace string `long:"namespace" default:"terraform" description:"The Rego package containing the 'deny' and 'warn' rules."`
+	Format    string `long:"format" default:"table" choice:"table" choice:"json" description:"The format to report violations in."`
+
+	Positional struct {
+		Policies []string `positional-arg-name:"policies" description:"The Rego policy files or directories to check the plan against."`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// Execute checks the configured plan against the configured policies, failing
+// if any resource is denied.
+func (c *CommandCheck) Execute(args []string) error {
+	ctx := context.Background()
+
+	p, err := plan.Load(c.Plan)
+	if err != nil {
+		return err
+	}
+
+	evaluator, err := NewEvaluator(ctx, c.Positional.Policies, c.Namespace)
+	if err != nil {
+		return err
+	}
+
+	violations, err := evaluator.Evaluate(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case "json":
+		violationsBytes, err := json.MarshalIndent(violations, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not marshal violations: %w", err)
+		}
+		fmt.Println(string(violationsBytes))
+	default:
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ADDRESS\tSEVERITY\tMESSAGE")
+		for _, v := range violations {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", v.Address, v.Severity, v.Message)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	denies := Count(violations, SeverityDeny)
+	log.Info().Int("deny", denies).Int("warn", Count(violations, SeverityWarn)).Msg("checked plan against policies")
+	if denies > 0 {
+		return fmt.Errorf("plan violates %d policies", denies)
+	}
+
+	return nil
+}
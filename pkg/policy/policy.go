@@ -0,0 +1,181 @@
+// Package policy evaluates Terraform plans against Rego policies with an
+// embedded Open Policy Agent evaluator.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/plan"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// Severity represents how a policy violation should be treated.
+type Severity string
+
+const (
+	// SeverityDeny indicates a violation which must prevent the plan from
+	// being applied.
+	SeverityDeny Severity = "deny"
+	// SeverityWarn indicates a violation which should only be reported.
+	SeverityWarn Severity = "warn"
+)
+
+// Violation represents a policy violation by a resource instance.
+type Violation struct {
+	Address  string   `json:"address"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Evaluator evaluates resource changes against a set of Rego policies.
+type Evaluator struct {
+	queries map[Severity]rego.PreparedEvalQuery
+}
+
+// NewEvaluator returns an Evaluator for the `deny` and `warn` rules in the
+// given namespace of the Rego policies found in the given files and
+// directories. Files ending in `_test.rego` are ignored.
+func NewEvaluator(ctx context.Context, paths []string, namespace string) (*Evaluator, error) {
+	modules, err := loadModules(paths)
+	if err != nil {
+		return nil, err
+	}
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("no Rego policies found in %s", strings.Join(paths, ", "))
+	}
+
+	e := &Evaluator{queries: map[Severity]rego.PreparedEvalQuery{}}
+	for _, severity := range []Severity{SeverityDeny, SeverityWarn} {
+		opts := []func(*rego.Rego){
+			rego.Query(fmt.Sprintf("data.%s.%s", namespace, severity)),
+		}
+		for _, file := range modules {
+			opts = append(opts, rego.Module(file.path, file.contents))
+		}
+
+		query, err := rego.New(opts...).PrepareForEval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not prepare policies: %w", err)
+		}
+		e.queries[severity] = query
+	}
+
+	return e, nil
+}
+
+type module struct {
+	path     string
+	contents string
+}
+
+func loadModules(paths []string) ([]*module, error) {
+	modules := []*module{}
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || filepath.Ext(path) != ".rego" || strings.HasSuffix(path, "_test.rego") {
+				return nil
+			}
+
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("could not read policy '%s': %w", path, err)
+			}
+			modules = append(modules, &module{path: path, contents: string(contents)})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not load policies from '%s': %w", p, err)
+		}
+	}
+
+	return modules, nil
+}
+
+// Evaluate evaluates every resource change in the given plan against the
+// policies. Each resource change from the plan's `resource_changes` is given as
+// the policy's `input`. Violations are returned sorted by address.
+func (e *Evaluator) Evaluate(ctx context.Context, p *plan.Plan) ([]*Violation, error) {
+	violations := []*Violation{}
+	for _, rc := range p.ResourceChanges {
+		for _, severity := range []Severity{SeverityDeny, SeverityWarn} {
+			messages, err := evaluate(ctx, e.queries[severity], rc)
+			if err != nil {
+				return nil, fmt.Errorf("could not evaluate '%s': %w", rc.Address, err)
+			}
+			for _, message := range messages {
+				violations = append(violations, &Violation{
+					Address:  rc.Address,
+					Severity: severity,
+					Message:  message,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Address < violations[j].Address
+	})
+
+	return violations, nil
+}
+
+func evaluate(ctx context.Context, query rego.PreparedEvalQuery, rc *plan.ResourceChange) ([]string, error) {
+	results, err := query.Eval(ctx, rego.EvalInput(rc))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []string{}
+	for _, result := range results {
+		for _, expr := range result.Expressions {
+			values, ok := expr.Value.([]interface{})
+			if !ok {
+				continue
+			}
+			for _, value := range values {
+				messages = append(messages, message(value))
+			}
+		}
+	}
+	sort.Strings(messages)
+
+	return messages, nil
+}
+
+// message returns the message of a rule's value, supporting both plain
+// strings and objects with a `msg` field.
+func message(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		if msg, ok := v["msg"].(string); ok {
+			return msg
+		}
+	}
+
+	valueBytes, _ := json.Marshal(value)
+	return string(valueBytes)
+}
+
+// Count returns the number of violations with the given severity.
+func Count(violations []*Violation, severity Severity) int {
+	count := 0
+	for _, v := range violations {
+		if v.Severity == severity {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,52 @@
+package policy_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/plan"
+	"github.com/VJftw/please-terraform/pkg/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluatorEvaluate(t *testing.T) {
+	ctx := context.Background()
+
+	p, err := plan.Load(filepath.Join("testdata", "plan.json"))
+	require.NoError(t, err)
+
+	evaluator, err := policy.NewEvaluator(ctx, []string{filepath.Join("testdata", "policies")}, "terraform")
+	require.NoError(t, err)
+
+	violations, err := evaluator.Evaluate(ctx, p)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*policy.Violation{
+		{Address: "aws_kms_key.main", Severity: policy.SeverityDeny, Message: "KMS keys must not be deleted"},
+		{Address: "aws_s3_bucket.logs", Severity: policy.SeverityDeny, Message: "aws_s3_bucket.logs must not be public"},
+		{Address: "aws_s3_bucket.logs", Severity: policy.SeverityWarn, Message: "aws_s3_bucket.logs should have a team tag"},
+	}, violations)
+	assert.Equal(t, 2, policy.Count(violations, policy.SeverityDeny))
+	assert.Equal(t, 1, policy.Count(violations, policy.SeverityWarn))
+}
+
+func TestNewEvaluatorNoPolicies(t *testing.T) {
+	_, err := policy.NewEvaluator(context.Background(), []string{t.TempDir()}, "terraform")
+	assert.ErrorContains(t, err, "no Rego policies found")
+}
+
+func TestEvaluatorEvaluateOtherNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	p, err := plan.Load(filepath.Join("testdata", "plan.json"))
+	require.NoError(t, err)
+
+	evaluator, err := policy.NewEvaluator(ctx, []string{filepath.Join("testdata", "policies", "s3.rego")}, "other")
+	require.NoError(t, err)
+
+	violations, err := evaluator.Evaluate(ctx, p)
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+}
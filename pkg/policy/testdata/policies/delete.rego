@@ -0,0 +1,7 @@
+package terraform
+
+deny[{"msg": msg}] {
+	input.change.actions[_] == "delete"
+	input.type == "aws_kms_key"
+	msg := "KMS keys must not be deleted"
+}
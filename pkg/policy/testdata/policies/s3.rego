@@ -0,0 +1,13 @@
+package terraform
+
+deny[msg] {
+	input.type == "aws_s3_bucket"
+	input.change.after.acl == "public-read"
+	msg := sprintf("%s must not be public", [input.address])
+}
+
+warn[msg] {
+	input.type == "aws_s3_bucket"
+	not input.change.after.tags.team
+	msg := sprintf("%s should have a team tag", [input.address])
+}
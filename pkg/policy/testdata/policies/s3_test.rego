@@ -0,0 +1,3 @@
+package terraform
+
+this is not valid rego and must be ignored
@@ -5,12 +5,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/VJftw/please-terraform/pkg/module"
 	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
 )
 
+// LockFileName is the name of Terraform's dependency lock file, which is
+// carried from a root's srcs into the root.
+const LockFileName = ".terraform.lock.hcl"
+
 // CommandBuild represents the build subcommand.
 type CommandBuild struct {
 	Pkg      string   `long:"pkg"`
@@ -21,9 +27,23 @@ type CommandBuild struct {
 	PkgDir   string   `long:"pkg_dir"`
 	Srcs     string   `long:"srcs"`
 	VarFiles []string `long:"var_files"`
-	Modules  []string `long:"modules"`
+
+	Environment   string   `long:"environment" description:"The environment of the root's matrix which this root is built for."`
+	Substitutions []string `long:"substitutions" description:"A '<NAME>=<value>' pair to substitute for '$<NAME>' in the root's Terraform files."`
+
+	Workspaces        []string `long:"workspaces" description:"The Terraform workspaces which the root may be run in."`
+	WorkspaceVarFiles []string `long:"workspace_var_files" description:"A '<workspace>=<var file>' pair of a var file which is only loaded in the given workspace."`
+	Modules           []string `long:"modules"`
+
+	DependsOnRoots []string `long:"depends_on_roots" description:"Other Terraform roots which must be applied before this Terraform root."`
+	RemoteStates   []string `long:"remote_states" description:"A '<name>=<built root>' pair of a root whose state is read by a generated 'terraform_remote_state' data source."`
+
+	ModuleOverrides []string `long:"module_overrides" description:"An '<alias>=<target>' pair choosing the module which an alias declared by several modules refers to."`
+
+	ValidateVarFiles string `long:"validate_var_files" default:"off" choice:"off" choice:"warn" choice:"error" description:"Whether to validate the root's var files against its variables, warning about or failing on problems."`
 
 	ModuleOpts *module.Opts
+	Opts       *Opts
 }
 
 // Execute executes the build subcommand.
@@ -35,17 +55,25 @@ func (c *CommandBuild) Execute(args []string) error {
 
 	// flatten
 	srcs := strings.Split(c.Srcs, " ")
+	lockFile := ""
 	for _, src := range srcs {
 		// flatten
 		if err := please.CopyFile(src, filepath.Join(c.Out, filepath.Base(src))); err != nil {
 			return fmt.Errorf("could not move file: %w", err)
 		}
+		if filepath.Base(src) == LockFileName {
+			lockFile = src
+		}
 	}
 
 	// Substitute build env vars into srcs
 	// This is useful for re-using a source file in multiple Terraform roots
 	// such as templating a Terraform remote state configuration.
-	err := filepath.Walk(c.Out, func(path string, fi os.FileInfo, err error) error {
+	substitutions, err := c.substitutions()
+	if err != nil {
+		return err
+	}
+	err = filepath.Walk(c.Out, func(path string, fi os.FileInfo, err error) error {
 		if filepath.Ext(path) == ".tf" {
 			log.Debug().
 				Str("path", path).
@@ -56,12 +84,7 @@ func (c *CommandBuild) Execute(args []string) error {
 				return fmt.Errorf("could not read '%s': %w", path, err)
 			}
 
-			newContents := bytes.ReplaceAll(tfContents, []byte("$PKG"), []byte(c.Pkg))
-			newContents = bytes.ReplaceAll(newContents, []byte("$PKG_DIR"), []byte(c.PkgDir))
-			newContents = bytes.ReplaceAll(newContents, []byte("$NAME"), []byte(c.Name))
-			newContents = bytes.ReplaceAll(newContents, []byte("$ARCH"), []byte(c.Arch))
-			newContents = bytes.ReplaceAll(newContents, []byte("$OS"), []byte(c.OS))
-
+			newContents := Substitute(tfContents, substitutions)
 			if err := os.WriteFile(path, newContents, 0644); err != nil {
 				return fmt.Errorf("could not write file '%s': %w", path, err)
 			}
@@ -78,30 +101,188 @@ func (c *CommandBuild) Execute(args []string) error {
 		if err != nil {
 			return err
 		}
+		if err := checkEncrypted(varFile); err != nil {
+			return err
+		}
 		if err := please.CopyFile(varFile, filepath.Join(c.Out, newName)); err != nil {
 			return fmt.Errorf("could not move var file '%s': %w", varFile, err)
 		}
 		log.Debug().Str("old_tfvars_name", varFile).Str("new_tfvars_name", newName).Msg("configured tfvars")
 	}
 
+	// Keep workspace var files aside so that only those of the selected
+	// workspace are auto-loaded.
+	workspaceVarFiles, err := ParseWorkspaceVarFiles(c.WorkspaceVarFiles)
+	if err != nil {
+		return err
+	}
+	if err := copyWorkspaceVarFiles(c.Out, WorkspacesDir(c.Opts.MetadataFile), workspaceVarFiles); err != nil {
+		return err
+	}
+
 	// colocate modules
-	if err := module.ColocateModules(c.ModuleOpts.MetadataFile, c.Out, c.Modules); err != nil {
+	overrides := map[string]string{}
+	for _, override := range c.ModuleOverrides {
+		alias, target, found := strings.Cut(override, "=")
+		if !found || alias == "" || target == "" {
+			return fmt.Errorf("module override '%s' is not in the form '<alias>=<target>'", override)
+		}
+		overrides[alias] = target
+	}
+	moduleTargets, err := module.ColocateModules(c.ModuleOpts.MetadataFile, c.Out, c.Modules, overrides)
+	if err != nil {
+		return err
+	}
+	if err := module.CheckUnresolvedSources(c.Out, "modules"); err != nil {
+		return err
+	}
+
+	// Generate the remote states of other roots from their backends, which
+	// these roots then depend on.
+	remoteStates, err := ParseRemoteStates(c.RemoteStates)
+	if err != nil {
+		return err
+	}
+	remoteStateTargets, err := WriteRemoteStates(c.Out, c.Opts.MetadataFile, remoteStates)
+	if err != nil {
+		return err
+	}
+	if c.DependsOnRoots == nil {
+		c.DependsOnRoots = []string{}
+	}
+	for _, target := range remoteStateTargets {
+		if !contains(c.DependsOnRoots, target) {
+			c.DependsOnRoots = append(c.DependsOnRoots, target)
+		}
+	}
+	m := &Metadata{
+		Target:         fmt.Sprintf("//%s:%s", c.Pkg, c.Name),
+		DependsOnRoots: c.DependsOnRoots,
+		Srcs:           srcs,
+		VarFiles:       c.VarFiles,
+		LockFile:       lockFile,
+		Environment:    c.Environment,
+		Workspaces:     workspaces(c.Workspaces, workspaceVarFiles),
+		Modules:        moduleTargets,
+	}
+
+	// Record the backend and remote states so that the order in which roots
+	// are applied can be determined without re-parsing their sources.
+	cfg, cfgErr := tfconfig.LoadDir(c.Out)
+	if cfgErr != nil {
+		log.Warn().Err(cfgErr).Msg("could not fully parse Terraform configuration")
+	}
+	if cfg != nil {
+		m.Backend = cfg.Backend
+		m.RemoteStates = cfg.RemoteStates
+	}
+
+	if len(workspaceVarFiles) > 0 {
+		m.WorkspaceVarFiles = workspaceVarFiles
+	}
+
+	// Variables may be missing from configuration which could not be parsed,
+	// which Terraform reports itself.
+	if c.ValidateVarFiles != VarFileValidationOff && cfgErr == nil {
+		if err := c.validateVarFiles(m, cfg.Variables); err != nil {
+			return err
+		}
+	}
+
+	if err := m.Save(filepath.Join(c.Out, c.Opts.MetadataFile)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// validateVarFiles logs the problems with the root's var files, failing if
+// they are to be treated as errors.
+func (c *CommandBuild) validateVarFiles(m *Metadata, variables []*tfconfig.Variable) error {
+	diags, err := ValidateVarFiles(c.Out, c.Opts.MetadataFile, m, variables)
+	if err != nil {
+		return err
+	}
+	for _, diag := range diags {
+		if c.ValidateVarFiles == VarFileValidationError {
+			log.Error().Msg(diag.Error())
+		} else {
+			log.Warn().Msg(diag.Error())
+		}
+	}
+	if len(diags) > 0 && c.ValidateVarFiles == VarFileValidationError {
+		return fmt.Errorf("%d problems with the var files of '%s'", len(diags), m.Target)
+	}
+
+	return nil
+}
+
+// substitutions returns the values to substitute into the root's Terraform
+// files, keyed by their names.
+func (c *CommandBuild) substitutions() (map[string]string, error) {
+	substitutions := map[string]string{
+		"PKG":     c.Pkg,
+		"PKG_DIR": c.PkgDir,
+		"NAME":    c.Name,
+		"ARCH":    c.Arch,
+		"OS":      c.OS,
+	}
+	if c.Environment != "" {
+		substitutions["ENV"] = c.Environment
+	}
+
+	for _, substitution := range c.Substitutions {
+		name, value, found := strings.Cut(substitution, "=")
+		if !found || name == "" {
+			return nil, fmt.Errorf("substitution '%s' is not in the form '<NAME>=<value>'", substitution)
+		}
+		if _, ok := substitutions[name]; ok {
+			return nil, fmt.Errorf("substitution '%s' cannot override the built-in '$%s'", substitution, name)
+		}
+		substitutions[name] = value
+	}
+
+	return substitutions, nil
+}
+
+// Substitute replaces `$<NAME>` in the given contents with the value of each
+// of the given substitutions. Longer names are replaced first so that, e.g.,
+// `$PKG_DIR` is not replaced as `$PKG` followed by `_DIR`.
+func Substitute(contents []byte, substitutions map[string]string) []byte {
+	names := make([]string, 0, len(substitutions))
+	for name := range substitutions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		contents = bytes.ReplaceAll(contents, []byte("$"+name), []byte(substitutions[name]))
+	}
+
+	return contents
+}
+
 // AutoTFVarsName returns a tfvars file name that will be automatically be
 // loaded by Terraform for given index and var file.
 func AutoTFVarsName(i int, varFile string) (string, error) {
 	baseName := filepath.Base(varFile)
 	switch {
+	// SOPS encrypted var files are only decrypted, and so auto-loaded, in the
+	// virtual env.
+	case strings.HasSuffix(baseName, SOPSTFVarsSuffix):
+		return fmt.Sprintf("%d-%s%s", i, strings.TrimSuffix(baseName, SOPSTFVarsSuffix), SOPSTFVarsSuffix), nil
+	case strings.HasSuffix(baseName, SOPSTFVarsJSONSuffix):
+		return fmt.Sprintf("%d-%s%s", i, strings.TrimSuffix(baseName, SOPSTFVarsJSONSuffix), SOPSTFVarsJSONSuffix), nil
 	case strings.HasSuffix(baseName, ".tfvars"):
 		return fmt.Sprintf("%d-%s.auto.tfvars", i, strings.TrimSuffix(baseName, ".tfvars")), nil
 	case strings.HasSuffix(baseName, ".tfvars.json"):
 		return fmt.Sprintf("%d-%s.auto.tfvars.json", i, strings.TrimSuffix(baseName, ".tfvars.json")), nil
 	}
 
-	return "", fmt.Errorf("'%s' does not end in '.tfvars' or '.tfvars.json'", baseName)
+	return "", fmt.Errorf("'%s' does not end in '.tfvars', '.tfvars.json', '%s' or '%s'", baseName, SOPSTFVarsSuffix, SOPSTFVarsJSONSuffix)
 }
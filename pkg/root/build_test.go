@@ -21,6 +21,8 @@ func TestAutoTFVarsName(t *testing.T) {
 		{1, "b.tfvars.json", "1-b.auto.tfvars.json", false},
 		{2, "a.tfvars", "2-a.auto.tfvars", false},
 		{2, "b.tfvars.json", "2-b.auto.tfvars.json", false},
+		{0, "c.sops.tfvars", "0-c.sops.tfvars", false},
+		{1, "d.sops.tfvars.json", "1-d.sops.tfvars.json", false},
 		{0, "a", "", true},
 	}
 
@@ -36,3 +38,20 @@ func TestAutoTFVarsName(t *testing.T) {
 		})
 	}
 }
+
+func TestSubstitute(t *testing.T) {
+	contents := []byte(`key = "$PKG/$NAME.tfstate"
+dir = "$PKG_DIR"
+region = "$REGION"
+`)
+	substituted := root.Substitute(contents, map[string]string{
+		"PKG":     "infra/network",
+		"PKG_DIR": "src/infra/network",
+		"NAME":    "network@eu-west-1",
+		"REGION":  "eu-west-1",
+	})
+	assert.Equal(t, `key = "infra/network/network@eu-west-1.tfstate"
+dir = "src/infra/network"
+region = "eu-west-1"
+`, string(substituted))
+}
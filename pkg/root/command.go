@@ -2,10 +2,16 @@ package root
 
 import "github.com/VJftw/please-terraform/internal/logging"
 
-var log = logging.NewLogger()
+var log = &logging.Logger
 
 // Command represents the root subcommand.
 type Command struct {
 	Build      *CommandBuild      `command:"build"`
+	Drift      *CommandDrift      `command:"drift"`
+	Licences   *CommandLicences   `command:"licences"`
+	Outputs    *CommandOutputs    `command:"outputs"`
+	Run        *CommandRun        `command:"run"`
+	Test       *CommandTest       `command:"test"`
+	Vars       *CommandVars       `command:"vars"`
 	VirtualEnv *CommandVirtualEnv `command:"virtualenv"`
 }
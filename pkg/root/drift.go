@@ -0,0 +1,88 @@
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/VJftw/please-terraform/pkg/plan"
+)
+
+// CommandDrift represents the drift subcommand.
+type CommandDrift struct {
+	TerraformBinary string `long:"terraform_binary" default:"terraform" description:"The Terraform binary to run."`
+	Dir             string `long:"dir" default:"." description:"The initialised Terraform working directory to detect drift in."`
+	Out             string `long:"out" description:"The file to write the JSON drift report to. Defaults to stdout."`
+
+	Positional struct {
+		PlanArgs []string `positional-arg-name:"plan_args" description:"Additional arguments to pass to 'terraform plan'."`
+	} `positional-args:"yes"`
+}
+
+// Execute detects whether the Terraform root in the configured directory has
+// drifted by running a refresh-only plan alongside a normal plan. It exits with
+// 0 when there is no drift, 2 when there is drift and 1 on errors, like
+// `terraform plan -detailed-exitcode`.
+func (c *CommandDrift) Execute(args []string) error {
+	tmpDir, err := os.MkdirTemp("", "please_terraform_drift_*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	refreshOnly, err := c.plan(filepath.Join(tmpDir, "refresh-only.tfplan"), "-refresh-only")
+	if err != nil {
+		return err
+	}
+
+	normal, err := c.plan(filepath.Join(tmpDir, "normal.tfplan"))
+	if err != nil {
+		return err
+	}
+
+	report := plan.NewDriftReport(refreshOnly, normal)
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal drift report: %w", err)
+	}
+
+	if c.Out == "" {
+		fmt.Println(string(reportBytes))
+	} else if err := os.WriteFile(c.Out, reportBytes, 0644); err != nil {
+		return fmt.Errorf("could not write drift report '%s': %w", c.Out, err)
+	}
+
+	if report.Drifted {
+		for _, drift := range report.Drift {
+			log.Warn().Str("address", drift.Address).Strs("actions", drift.Actions).Strs("attributes", drift.Attributes).Msg("resource changed outside of Terraform")
+		}
+		return &ExitError{Code: 2, Err: fmt.Errorf("%d resources have drifted", len(report.Drift))}
+	}
+
+	log.Info().Int("pending_changes", len(report.PendingChanges)).Msg("no drift detected")
+	return nil
+}
+
+// plan runs `terraform plan` with the given extra arguments, saving the plan
+// to the given file, and returns the parsed plan.
+func (c *CommandDrift) plan(planFile string, extraArgs ...string) (*plan.Plan, error) {
+	planArgs := append([]string{"plan", "-input=false", "-detailed-exitcode", "-out=" + planFile}, extraArgs...)
+	planArgs = append(planArgs, c.Positional.PlanArgs...)
+
+	exitCode, err := runTerraform(c.TerraformBinary, c.Dir, planArgs...)
+	if err != nil {
+		return nil, err
+	}
+	// With -detailed-exitcode, 0 means no changes and 2 means changes.
+	if exitCode != 0 && exitCode != 2 {
+		return nil, fmt.Errorf("'terraform plan' exited with %d", exitCode)
+	}
+
+	planJSON, err := terraformOutput(c.TerraformBinary, c.Dir, "show", "-json", planFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.Parse(planJSON)
+}
@@ -0,0 +1,177 @@
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/VJftw/please-terraform/pkg/please"
+)
+
+// CommandLicences represents the licences subcommand.
+type CommandLicences struct {
+	Format string `long:"format" default:"table" choice:"table" choice:"json" description:"The format to report licences in."`
+
+	Positional struct {
+		Roots []string `positional-arg-name:"roots" description:"Built Terraform root directories to report on. Defaults to every Terraform root built under plz-out/gen."`
+	} `positional-args:"yes"`
+
+	PleaseOpts *please.Opts
+	ModuleOpts *module.Opts
+	Opts       *Opts
+}
+
+// LicenceReport represents the licences of the modules used by a Terraform
+// root.
+type LicenceReport struct {
+	Root    string            `json:"root"`
+	Modules []*ModuleLicences `json:"modules"`
+}
+
+// ModuleLicences represents the licences detected in a registry module.
+type ModuleLicences struct {
+	Target   string   `json:"target"`
+	Licences []string `json:"licences"`
+}
+
+// Execute reports the licences of the registry modules used by Terraform
+// roots, as detected when they were built.
+func (c *CommandLicences) Execute(args []string) error {
+	rootDirs := c.Positional.Roots
+	if len(rootDirs) == 0 {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("could not get current working directory: %w", err)
+		}
+		repoRoot, err := please.FindRepoRoot(cwd)
+		if err != nil {
+			return err
+		}
+
+		genDir := filepath.Join(repoRoot, c.PleaseOpts.PlzOutDir, "gen")
+		rootDirs, err = FindBuiltRoots(genDir, c.Opts.MetadataFile)
+		if err != nil {
+			return err
+		}
+		if len(rootDirs) == 0 {
+			log.Warn().Str("path", genDir).Msg("no built Terraform roots found, have they been built?")
+		}
+	}
+
+	reports := []*LicenceReport{}
+	for _, rootDir := range rootDirs {
+		report, err := NewLicenceReport(rootDir, c.Opts.MetadataFile, c.ModuleOpts.MetadataFile)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Root < reports[j].Root })
+
+	switch c.Format {
+	case "json":
+		reportsBytes, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not marshal licence report: %w", err)
+		}
+		fmt.Println(string(reportsBytes))
+	default:
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ROOT\tMODULE\tLICENCES")
+		for _, report := range reports {
+			for _, m := range report.Modules {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", report.Root, m.Target, strings.Join(m.Licences, ", "))
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindBuiltRoots returns the directories of the Terraform roots built under
+// the given directory, e.g. `plz-out/gen`.
+func FindBuiltRoots(dir string, metadataFile string) ([]string, error) {
+	rootDirs := []string{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".modules" {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(p, metadataFile)); err == nil {
+			rootDirs = append(rootDirs, p)
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not walk '%s': %w", dir, err)
+	}
+
+	return rootDirs, nil
+}
+
+// NewLicenceReport returns the licences of the registry modules colocated in
+// the given built Terraform root, including the modules that those modules
+// depend on.
+func NewLicenceReport(rootDir string, rootMetadataFile string, moduleMetadataFile string) (*LicenceReport, error) {
+	rootMeta, err := LoadMetadata(filepath.Join(rootDir, rootMetadataFile))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LicenceReport{Root: rootMeta.Target, Modules: []*ModuleLicences{}}
+	seen := map[string]struct{}{}
+	modulesDir := filepath.Join(rootDir, ".modules")
+	if _, err := os.Stat(modulesDir); os.IsNotExist(err) {
+		return report, nil
+	}
+
+	err = filepath.WalkDir(modulesDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(filepath.ToSlash(p), "/"+moduleMetadataFile) {
+			return nil
+		}
+
+		moduleMeta, err := module.Load(p)
+		if err != nil {
+			return err
+		}
+		// Local modules do not have licences detected.
+		if len(moduleMeta.Licences) == 0 {
+			return nil
+		}
+		if _, ok := seen[moduleMeta.Target]; ok {
+			return nil
+		}
+		seen[moduleMeta.Target] = struct{}{}
+		report.Modules = append(report.Modules, &ModuleLicences{
+			Target:   moduleMeta.Target,
+			Licences: moduleMeta.Licences,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not walk '%s': %w", modulesDir, err)
+	}
+	sort.Slice(report.Modules, func(i, j int) bool { return report.Modules[i].Target < report.Modules[j].Target })
+
+	return report, nil
+}
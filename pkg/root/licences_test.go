@@ -0,0 +1,56 @@
+package root_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/module"
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLicenceReport(t *testing.T) {
+	const (
+		rootMetadataFile   = ".please/terraform/root.json"
+		moduleMetadataFile = ".please/terraform/module.json"
+	)
+
+	genDir := t.TempDir()
+	rootDir := filepath.Join(genDir, "infra/app/app_root")
+	require.NoError(t, (&root.Metadata{Target: "//infra/app:app"}).Save(filepath.Join(rootDir, rootMetadataFile)))
+
+	modulesDir := filepath.Join(rootDir, ".modules")
+	for path, m := range map[string]*module.Metadata{
+		"modules/vpc/vpc": {Target: "//modules/vpc:vpc"},
+		"third_party/terraform/label": {
+			Target:   "//third_party/terraform:label",
+			Licences: []string{"Apache-2.0"},
+		},
+		// modules colocated into other modules.
+		"modules/vpc/vpc/.modules/third_party/terraform/label": {
+			Target:   "//third_party/terraform:label",
+			Licences: []string{"Apache-2.0"},
+		},
+		"modules/vpc/vpc/.modules/third_party/terraform/subnets": {
+			Target:   "//third_party/terraform:subnets",
+			Licences: []string{"NOASSERTION"},
+		},
+	} {
+		require.NoError(t, m.Save(filepath.Join(modulesDir, path, moduleMetadataFile)))
+	}
+
+	rootDirs, err := root.FindBuiltRoots(genDir, rootMetadataFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{rootDir}, rootDirs)
+
+	report, err := root.NewLicenceReport(rootDir, rootMetadataFile, moduleMetadataFile)
+	require.NoError(t, err)
+	assert.Equal(t, &root.LicenceReport{
+		Root: "//infra/app:app",
+		Modules: []*root.ModuleLicences{
+			{Target: "//third_party/terraform:label", Licences: []string{"Apache-2.0"}},
+			{Target: "//third_party/terraform:subnets", Licences: []string{"NOASSERTION"}},
+		},
+	}, report)
+}
@@ -0,0 +1,77 @@
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+)
+
+// Opts represent the available options to this Root package as a whole.
+type Opts struct {
+	// The file in which Please root metadata is stored in relative to each root.
+	MetadataFile string `long:"root_metadata_file" default:".please/terraform/root.json" description:"The file in which Please root metadata is stored in relative to each root."`
+}
+
+// Metadata represents a root's metadata.
+type Metadata struct {
+	Target string
+	// Srcs are the source files of the root relative to the repository root.
+	Srcs []string `json:",omitempty"`
+	// VarFiles are the var files of the root relative to the repository root.
+	VarFiles []string `json:",omitempty"`
+	// Environment is the environment of the root's matrix which the root was
+	// built for, if any.
+	Environment string `json:",omitempty"`
+	// Workspaces are the Terraform workspaces which the root may be run in.
+	// Any workspace may be used if there are none.
+	Workspaces []string `json:",omitempty"`
+	// WorkspaceVarFiles are the var files of each workspace relative to the
+	// repository root.
+	WorkspaceVarFiles map[string][]string `json:",omitempty"`
+	// LockFile is the Terraform dependency lock file of the root relative to
+	// the repository root, if it has one.
+	LockFile string `json:",omitempty"`
+	// Modules are the targets of the modules that the root uses.
+	Modules        []string `json:",omitempty"`
+	DependsOnRoots []string
+	Backend        *tfconfig.Backend
+	RemoteStates   []*tfconfig.RemoteState
+}
+
+// LoadMetadata returns a root's Metadata loaded from the given path.
+func LoadMetadata(path string) (*Metadata, error) {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read saved root: %w", err)
+	}
+
+	m := &Metadata{}
+	if err := json.Unmarshal(fileBytes, m); err != nil {
+		return nil, fmt.Errorf("could not unmarshal saved root: %w", err)
+	}
+
+	return m, nil
+}
+
+// Save saves the Metadata data to be re-used in other workflows.
+func (m *Metadata) Save(path string) error {
+	fileBytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal root: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("could not create directory '%s': %w", dir, err)
+	}
+
+	if err := os.WriteFile(path, fileBytes, 0644); err != nil {
+		return fmt.Errorf("could not write '%s': %w", path, err)
+	}
+
+	log.Debug().Str("file", path).Msg("saved root metadata")
+	return nil
+}
@@ -0,0 +1,152 @@
+package root
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+)
+
+// SensitiveValue is the value which outputs marked as sensitive are masked
+// with.
+const SensitiveValue = "(sensitive value)"
+
+// CommandOutputs represents the `root outputs` command and its flags.
+type CommandOutputs struct {
+	TerraformBinary   string   `long:"terraform_binary" required:"true" description:"The Terraform binary to run."`
+	RootModule        string   `long:"root_module" required:"true" description:"The built Terraform root to read the outputs of."`
+	VirtualEnvBaseDir string   `long:"virtual_env_base_dir" default:"./plz-out/terraform/venvs" description:"The directory to create the Terraform root's virtual env in."`
+	PreCmds           []string `long:"pre_cmd" description:"Shell commands to run in the virtual env before running Terraform."`
+	Init              bool     `long:"init" description:"Run 'terraform init' before reading the outputs."`
+	InitArgs          []string `long:"init_args" description:"Additional arguments to pass to 'terraform init'."`
+	Workspace         string   `long:"workspace" env:"PLEASE_TERRAFORM_WORKSPACE" description:"The Terraform workspace to read the outputs of."`
+	Outputs           []string `long:"outputs" description:"An output to include. Defaults to all outputs."`
+	AllowSensitive    []string `long:"allow_sensitive" description:"A sensitive output whose value is included rather than masked."`
+	Format            string   `long:"format" default:"json" choice:"json" choice:"dotenv" description:"The format to write the outputs in."`
+	Out               string   `long:"out" description:"The file to write the outputs to. Defaults to stdout."`
+
+	PleaseOpts *please.Opts
+	Opts       *Opts
+}
+
+// Output represents an output of `terraform output -json`.
+type Output struct {
+	Sensitive bool            `json:"sensitive"`
+	Type      json.RawMessage `json:"type"`
+	Value     interface{}     `json:"value"`
+}
+
+// Execute writes the outputs of the configured Terraform root in the
+// configured format.
+func (c *CommandOutputs) Execute(args []string) error {
+	stdout := &bytes.Buffer{}
+	run := &CommandRun{
+		TerraformBinary:   c.TerraformBinary,
+		RootModule:        c.RootModule,
+		VirtualEnvBaseDir: c.VirtualEnvBaseDir,
+		PreCmds:           c.PreCmds,
+		Init:              c.Init,
+		InitArgs:          c.InitArgs,
+		Workspace:         c.Workspace,
+		PleaseOpts:        c.PleaseOpts,
+		Opts:              c.Opts,
+		stdout:            stdout,
+	}
+	run.Positional.Args = []string{"output", "-json"}
+	if err := run.Execute(nil); err != nil {
+		return err
+	}
+
+	outputs := map[string]*Output{}
+	if err := json.Unmarshal(stdout.Bytes(), &outputs); err != nil {
+		return fmt.Errorf("could not unmarshal Terraform outputs: %w", err)
+	}
+
+	values, err := FilterOutputs(outputs, c.Outputs, c.AllowSensitive)
+	if err != nil {
+		return err
+	}
+
+	var outBytes []byte
+	switch c.Format {
+	case "dotenv":
+		outBytes, err = DotEnv(values)
+	default:
+		outBytes, err = json.MarshalIndent(values, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("could not format outputs: %w", err)
+	}
+
+	if c.Out == "" {
+		fmt.Println(string(outBytes))
+	} else if err := os.WriteFile(c.Out, outBytes, 0644); err != nil {
+		return fmt.Errorf("could not write outputs '%s': %w", c.Out, err)
+	}
+
+	return nil
+}
+
+// FilterOutputs returns the values of the given outputs keyed by their names,
+// only including the given names if any are given. The values of sensitive
+// outputs are masked unless they are allowed.
+func FilterOutputs(outputs map[string]*Output, names []string, allowSensitive []string) (map[string]interface{}, error) {
+	if len(names) == 0 {
+		for name := range outputs {
+			names = append(names, name)
+		}
+	}
+
+	values := map[string]interface{}{}
+	for _, name := range names {
+		output, ok := outputs[name]
+		if !ok {
+			return nil, fmt.Errorf("the root does not have an output named '%s'", name)
+		}
+		if output.Sensitive && !contains(allowSensitive, name) {
+			log.Warn().Str("output", name).Msg("masking sensitive output, allow it with --allow_sensitive to include its value")
+			values[name] = SensitiveValue
+			continue
+		}
+		values[name] = output.Value
+	}
+
+	for _, name := range allowSensitive {
+		if _, ok := values[name]; !ok {
+			log.Warn().Str("output", name).Msg("allowed sensitive output is not included")
+		}
+	}
+
+	return values, nil
+}
+
+// DotEnv returns the given values as a dotenv file of quoted values. The
+// names are upper-cased and values which are not strings are JSON encoded.
+func DotEnv(values map[string]interface{}) ([]byte, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := &bytes.Buffer{}
+	for _, name := range names {
+		value, ok := values[name].(string)
+		if !ok {
+			valueBytes, err := json.Marshal(values[name])
+			if err != nil {
+				return nil, fmt.Errorf("could not marshal output '%s': %w", name, err)
+			}
+			value = string(valueBytes)
+		}
+		key := strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		fmt.Fprintf(out, "%s=%s\n", key, strconv.Quote(value))
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,84 @@
+package root_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandOutputsExecute(t *testing.T) {
+	run := newRunCommand(t, `echo '{
+  "bucket": {"sensitive": false, "type": "string", "value": "my-bucket"},
+  "subnets": {"sensitive": false, "type": ["list", "string"], "value": ["a", "b"]},
+  "password": {"sensitive": true, "type": "string", "value": "hunter2"},
+  "token": {"sensitive": true, "type": "string", "value": "abc"}
+}'`)
+	out := filepath.Join(t.TempDir(), "outputs")
+
+	var tests = []struct {
+		description    string
+		format         string
+		outputs        []string
+		allowSensitive []string
+		expected       string
+		expectedErr    string
+	}{
+		{
+			description: "masks sensitive outputs",
+			format:      "json",
+			expected: `{
+  "bucket": "my-bucket",
+  "password": "(sensitive value)",
+  "subnets": [
+    "a",
+    "b"
+  ],
+  "token": "(sensitive value)"
+}`,
+		},
+		{
+			description:    "filters outputs as dotenv",
+			format:         "dotenv",
+			outputs:        []string{"bucket", "subnets", "password"},
+			allowSensitive: []string{"password"},
+			expected:       "BUCKET=\"my-bucket\"\nPASSWORD=\"hunter2\"\nSUBNETS=\"[\\\"a\\\",\\\"b\\\"]\"\n",
+		},
+		{
+			description: "fails on missing outputs",
+			format:      "json",
+			outputs:     []string{"endpoint"},
+			expectedErr: "the root does not have an output named 'endpoint'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			c := &root.CommandOutputs{
+				TerraformBinary:   run.TerraformBinary,
+				RootModule:        run.RootModule,
+				VirtualEnvBaseDir: run.VirtualEnvBaseDir,
+				Outputs:           tt.outputs,
+				AllowSensitive:    tt.allowSensitive,
+				Format:            tt.format,
+				Out:               out,
+				PleaseOpts:        run.PleaseOpts,
+				Opts:              run.Opts,
+			}
+
+			err := c.Execute(nil)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			actual, err := os.ReadFile(out)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(actual))
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RemoteStatesFile is the file in which the `terraform_remote_state` data
+// sources of a root's remote states are generated.
+const RemoteStatesFile = "please_remote_states.tf.json"
+
+// ParseRemoteStates returns the built roots of the given
+// '<name>=<built root>' pairs keyed by their data source names.
+func ParseRemoteStates(pairs []string) (map[string]string, error) {
+	remoteStates := map[string]string{}
+	for _, pair := range pairs {
+		name, rootDir, found := strings.Cut(pair, "=")
+		if !found || name == "" || rootDir == "" {
+			return nil, fmt.Errorf("remote state '%s' is not in the form '<name>=<root>'", pair)
+		}
+		if _, ok := remoteStates[name]; ok {
+			return nil, fmt.Errorf("remote state '%s' is specified more than once", name)
+		}
+		remoteStates[name] = rootDir
+	}
+
+	return remoteStates, nil
+}
+
+// WriteRemoteStates generates a `terraform_remote_state` data source in the
+// given directory for each of the given built roots, keyed by the names of
+// the data sources, which reads the state of the root from its backend. The
+// targets of the roots are returned so that they can be recorded as
+// dependencies.
+func WriteRemoteStates(dir, metadataFile string, remoteStates map[string]string) ([]string, error) {
+	if len(remoteStates) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(remoteStates))
+	for name := range remoteStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dataSources := map[string]interface{}{}
+	targets := []string{}
+	for _, name := range names {
+		m, err := LoadMetadata(filepath.Join(remoteStates[name], metadataFile))
+		if err != nil {
+			return nil, fmt.Errorf("could not load root of remote state '%s': %w", name, err)
+		}
+		if m.Backend == nil {
+			return nil, fmt.Errorf("remote state '%s' refers to '%s' which does not configure a backend", name, m.Target)
+		}
+		// An incomplete configuration would read another state, or none.
+		if len(m.Backend.Unresolved) > 0 {
+			return nil, fmt.Errorf("remote state '%s' refers to '%s' whose backend configuration is not static: %s", name, m.Target, strings.Join(m.Backend.Unresolved, ", "))
+		}
+		if missing := m.Backend.MissingConfig(); len(missing) > 0 {
+			return nil, fmt.Errorf("remote state '%s' refers to '%s' whose backend does not configure %s, which may be given to 'terraform init' with -backend-config", name, m.Target, strings.Join(missing, ", "))
+		}
+		if len(m.Backend.Config) == 0 {
+			return nil, fmt.Errorf("remote state '%s' refers to '%s' whose backend has no configuration, which may be given to 'terraform init' with -backend-config", name, m.Target)
+		}
+
+		log.Debug().Str("name", name).Str("root", m.Target).Str("backend", m.Backend.Type).Msg("generating remote state")
+		dataSources[name] = map[string]interface{}{
+			"backend": m.Backend.Type,
+			"config":  m.Backend.Config,
+		}
+		targets = append(targets, m.Target)
+	}
+
+	fileBytes, err := json.MarshalIndent(map[string]interface{}{
+		"data": map[string]interface{}{
+			"terraform_remote_state": dataSources,
+		},
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal remote states: %w", err)
+	}
+
+	path := filepath.Join(dir, RemoteStatesFile)
+	if err := os.WriteFile(path, fileBytes, 0644); err != nil {
+		return nil, fmt.Errorf("could not write '%s': %w", path, err)
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,88 @@
+package root_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRemoteStates(t *testing.T) {
+	remoteStates, err := root.ParseRemoteStates([]string{"network=plz-out/gen/infra/network/network_root"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"network": "plz-out/gen/infra/network/network_root"}, remoteStates)
+
+	_, err = root.ParseRemoteStates([]string{"network"})
+	assert.ErrorContains(t, err, "is not in the form '<name>=<root>'")
+
+	_, err = root.ParseRemoteStates([]string{"network=a", "network=b"})
+	assert.ErrorContains(t, err, "remote state 'network' is specified more than once")
+}
+
+func TestWriteRemoteStates(t *testing.T) {
+	dir := t.TempDir()
+	metadataFile := ".please/terraform/root.json"
+
+	backend := &tfconfig.Backend{
+		Type: "s3",
+		Config: map[string]interface{}{
+			"bucket": "my-terraform-state",
+			"key":    "infra/network/network.tfstate",
+		},
+	}
+	network := filepath.Join(dir, "network_root")
+	require.NoError(t, (&root.Metadata{Target: "//infra/network:network", Backend: backend}).Save(filepath.Join(network, metadataFile)))
+	local := filepath.Join(dir, "local_root")
+	require.NoError(t, (&root.Metadata{Target: "//infra/local:local"}).Save(filepath.Join(local, metadataFile)))
+
+	out := filepath.Join(dir, "app_root")
+	require.NoError(t, os.MkdirAll(out, 0750))
+	targets, err := root.WriteRemoteStates(out, metadataFile, map[string]string{"network": network})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"//infra/network:network"}, targets)
+
+	cfg, err := tfconfig.LoadDir(out)
+	require.NoError(t, err)
+	require.Len(t, cfg.RemoteStates, 1)
+	assert.Equal(t, "network", cfg.RemoteStates[0].Name)
+	assert.True(t, backend.Matches(cfg.RemoteStates[0].Backend))
+
+	_, err = root.WriteRemoteStates(out, metadataFile, map[string]string{"local": local})
+	assert.ErrorContains(t, err, "remote state 'local' refers to '//infra/local:local' which does not configure a backend")
+
+	var tests = []struct {
+		description string
+		backend     *tfconfig.Backend
+		expectedErr string
+	}{
+		{
+			description: "key from a variable",
+			backend:     &tfconfig.Backend{Type: "s3", Config: map[string]interface{}{"bucket": "my-terraform-state"}, Unresolved: []string{"key"}},
+			expectedErr: "remote state 'cluster' refers to '//infra/cluster:cluster' whose backend configuration is not static: key",
+		},
+		{
+			description: "partial configuration",
+			backend:     &tfconfig.Backend{Type: "s3", Config: map[string]interface{}{"bucket": "my-terraform-state"}},
+			expectedErr: "remote state 'cluster' refers to '//infra/cluster:cluster' whose backend does not configure key, which may be given to 'terraform init' with -backend-config",
+		},
+		{
+			description: "empty configuration",
+			backend:     &tfconfig.Backend{Type: "oss", Config: map[string]interface{}{}},
+			expectedErr: "remote state 'cluster' refers to '//infra/cluster:cluster' whose backend has no configuration",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			cluster := filepath.Join(t.TempDir(), "cluster_root")
+			require.NoError(t, (&root.Metadata{Target: "//infra/cluster:cluster", Backend: tt.backend}).Save(filepath.Join(cluster, metadataFile)))
+
+			_, err := root.WriteRemoteStates(out, metadataFile, map[string]string{"cluster": cluster})
+			assert.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
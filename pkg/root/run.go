@@ -0,0 +1,249 @@
+package root
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/policy"
+)
+
+// policyPlanFile is the plan which is checked against policies before it is
+// applied.
+const policyPlanFile = "please.tfplan"
+
+// applyOnlyFlags are the flags of `apply` which `plan` does not accept.
+var applyOnlyFlags = []string{"-auto-approve", "-backup", "-state-out"}
+
+// savedPlanApplyFlags are the flags which `apply` accepts alongside a saved
+// plan. Planning options, such as `-var` and `-target`, are given to the plan.
+var savedPlanApplyFlags = []string{"-auto-approve", "-backup", "-compact-warnings", "-json", "-lock", "-lock-timeout", "-no-color", "-parallelism", "-state", "-state-out"}
+
+// valueFlags are the flags of `plan` and `apply` which take a value, which
+// may be given as the next argument, e.g. `-var 'a=b'`, rather than after `=`.
+var valueFlags = []string{"-backup", "-generate-config-out", "-lock-timeout", "-out", "-parallelism", "-replace", "-state", "-state-out", "-target", "-var", "-var-file"}
+
+// CommandRun represents the `root run` command and its flags.
+type CommandRun struct {
+	TerraformBinary   string   `long:"terraform_binary" required:"true" description:"The Terraform binary to run."`
+	RootModule        string   `long:"root_module" required:"true" description:"The built Terraform root to run Terraform against."`
+	VirtualEnvBaseDir string   `long:"virtual_env_base_dir" default:"./plz-out/terraform/venvs" description:"The directory to create the Terraform root's virtual env in."`
+	PreCmds           []string `long:"pre_cmd" description:"Shell commands to run in the virtual env before running Terraform."`
+	PostCmds          []string `long:"post_cmd" description:"Shell commands to run in the virtual env after Terraform succeeds."`
+	Init              bool     `long:"init" description:"Run 'terraform init' before running Terraform."`
+	InitArgs          []string `long:"init_args" description:"Additional arguments to pass to 'terraform init'."`
+	Policies          []string `long:"policies" description:"Space separated Rego policy files or directories which the plan of a 'plan' or 'apply' must pass. The checked plan is the one which is applied."`
+	PolicyNamespace   string   `long:"policy_namespace" default:"terraform" description:"The Rego package containing the 'deny' and 'warn' rules."`
+	Drift             bool     `long:"drift" description:"Detect drift, as 'root drift' does, instead of running Terraform with the given arguments."`
+	Workspace         string   `long:"workspace" env:"PLEASE_TERRAFORM_WORKSPACE" description:"The Terraform workspace to select, or create, after init. Only its workspace var files are loaded."`
+	Shell             bool     `long:"shell" description:"Run the given arguments as shell commands in the virtual env, e.g. 'terraform init && terraform console', instead of as Terraform's arguments."`
+
+	PleaseOpts *please.Opts
+	Opts       *Opts
+
+	Positional struct {
+		Args []string `positional-arg-name:"terraform_args" description:"The arguments to run Terraform with, e.g. 'plan -lock=false'."`
+	} `positional-args:"yes"`
+
+	// stdout is where Terraform's output for the given arguments is written.
+	// Defaults to os.Stdout.
+	stdout io.Writer
+}
+
+// Execute runs Terraform with the configured arguments in the virtual env of
+// the configured Terraform root, exiting with Terraform's exit code.
+func (c *CommandRun) Execute(args []string) error {
+	repoRoot := please.MustRepoRoot(c.PleaseOpts.PlzOutDir)
+	if strings.HasPrefix(c.VirtualEnvBaseDir, "./") {
+		c.VirtualEnvBaseDir = filepath.Join(repoRoot, c.VirtualEnvBaseDir)
+	}
+	c.TerraformBinary = absTerraformBinary(repoRoot, c.PleaseOpts.PlzOutDir, c.TerraformBinary)
+
+	virtualEnvDir, err := NewVirtualEnv(repoRoot, c.PleaseOpts.PlzOutDir, c.VirtualEnvBaseDir, c.RootModule)
+	if err != nil {
+		return err
+	}
+	log.Info().Str("path", virtualEnvDir).Msg("working directory")
+
+	if c.Workspace != "" {
+		if err := UseWorkspace(c.RootModule, c.Opts.MetadataFile, virtualEnvDir, c.Workspace); err != nil {
+			return err
+		}
+	}
+	if err := DecryptVarFiles(repoRoot, c.PleaseOpts.PlzOutDir, virtualEnvDir); err != nil {
+		return err
+	}
+
+	// Hooks and Terraform itself see the same environment as a shell which
+	// has sourced `root virtualenv`.
+	env := append(os.Environ(),
+		fmt.Sprintf("PATH=%s%c%s", filepath.Dir(c.TerraformBinary), os.PathListSeparator, os.Getenv("PATH")),
+		fmt.Sprintf("REPO_ROOT=%s", repoRoot),
+	)
+
+	for _, preCmd := range c.PreCmds {
+		if err := runHook(virtualEnvDir, env, preCmd); err != nil {
+			return err
+		}
+	}
+
+	if c.Init {
+		initArgs := append([]string{"init", "-input=false"}, c.InitArgs...)
+		if err := c.terraform(os.Stderr, virtualEnvDir, env, initArgs...); err != nil {
+			return err
+		}
+	}
+
+	if c.Workspace != "" {
+		log.Info().Str("workspace", c.Workspace).Msg("selecting workspace")
+		if err := c.terraform(os.Stderr, virtualEnvDir, env, "workspace", "select", "-or-create", c.Workspace); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case c.Shell:
+		if err := runHook(virtualEnvDir, env, "set -x; "+strings.Join(c.Positional.Args, " ")); err != nil {
+			return err
+		}
+	case c.Drift:
+		drift := &CommandDrift{TerraformBinary: c.TerraformBinary, Dir: virtualEnvDir}
+		drift.Positional.PlanArgs = c.Positional.Args
+		if err := drift.Execute(nil); err != nil {
+			return err
+		}
+	case len(c.Policies) > 0 && len(c.Positional.Args) > 0 && (c.Positional.Args[0] == "plan" || c.Positional.Args[0] == "apply"):
+		if err := c.runWithPolicies(virtualEnvDir, env); err != nil {
+			return err
+		}
+	default:
+		stdout := c.stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+		if err := c.terraform(stdout, virtualEnvDir, env, c.Positional.Args...); err != nil {
+			return err
+		}
+	}
+
+	for _, postCmd := range c.PostCmds {
+		if err := runHook(virtualEnvDir, env, postCmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runWithPolicies saves a plan and checks it against the configured policies,
+// applying the checked plan if the configured arguments are for `apply`. The
+// plan is made with the configured arguments so that the checked plan is the
+// one which was asked for.
+func (c *CommandRun) runWithPolicies(dir string, env []string) error {
+	command, extraArgs := c.Positional.Args[0], c.Positional.Args[1:]
+
+	planArgs := append([]string{"plan", "-out=" + policyPlanFile}, filterFlags(extraArgs, applyOnlyFlags, false)...)
+	if err := c.terraform(os.Stdout, dir, env, planArgs...); err != nil {
+		return err
+	}
+
+	planJSON, err := terraformOutput(c.TerraformBinary, dir, "show", "-json", policyPlanFile)
+	if err != nil {
+		return err
+	}
+	planJSONFile := filepath.Join(dir, policyPlanFile+".json")
+	if err := os.WriteFile(planJSONFile, planJSON, 0644); err != nil {
+		return fmt.Errorf("could not write '%s': %w", planJSONFile, err)
+	}
+
+	check := &policy.CommandCheck{
+		Plan:      planJSONFile,
+		Namespace: c.PolicyNamespace,
+		Format:    "table",
+	}
+	for _, policies := range c.Policies {
+		check.Positional.Policies = append(check.Positional.Policies, strings.Fields(policies)...)
+	}
+	if err := check.Execute(nil); err != nil {
+		return err
+	}
+
+	if command == "apply" {
+		applyArgs := append(append([]string{"apply", "-input=false"}, filterFlags(extraArgs, savedPlanApplyFlags, true)...), policyPlanFile)
+		return c.terraform(os.Stdout, dir, env, applyArgs...)
+	}
+
+	return nil
+}
+
+// filterFlags returns the given arguments which are, if keep is true, or are
+// not, if keep is false, one of the given flags, with or without a value.
+// The values of valueFlags given as the next argument are kept with their
+// flag.
+func filterFlags(args []string, flags []string, keep bool) []string {
+	filtered := []string{}
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(args[i], "=")
+		// Terraform accepts flags with one or two dashes.
+		if strings.HasPrefix(name, "--") {
+			name = name[1:]
+		}
+		end := i + 1
+		if !hasValue && contains(valueFlags, name) && end < len(args) {
+			end++
+		}
+		if contains(flags, name) == keep {
+			filtered = append(filtered, args[i:end]...)
+		}
+		i = end - 1
+	}
+
+	return filtered
+}
+
+// terraform runs Terraform with the given arguments, returning an ExitError
+// with Terraform's exit code if it does not succeed.
+func (c *CommandRun) terraform(stdout io.Writer, dir string, env []string, args ...string) error {
+	cmd := exec.Command(c.TerraformBinary, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+
+	log.Debug().Str("dir", dir).Strs("args", args).Msg("running terraform")
+	exitCode, err := runForwardingSignals(cmd)
+	if err != nil {
+		return fmt.Errorf("could not run 'terraform %s': %w", strings.Join(args, " "), err)
+	}
+	if exitCode != 0 {
+		return &ExitError{Code: exitCode, Err: fmt.Errorf("'terraform %s' exited with %d", strings.Join(args, " "), exitCode)}
+	}
+
+	return nil
+}
+
+// runHook runs the given shell commands in the given directory.
+func runHook(dir string, env []string, hook string) error {
+	cmd := exec.Command("bash", "-Eeu", "-o", "pipefail", "-c", hook)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	log.Debug().Str("dir", dir).Str("hook", hook).Msg("running hook")
+	exitCode, err := runForwardingSignals(cmd)
+	if err != nil {
+		return fmt.Errorf("could not run hook '%s': %w", hook, err)
+	}
+	if exitCode != 0 {
+		return &ExitError{Code: exitCode, Err: fmt.Errorf("hook '%s' exited with %d", hook, exitCode)}
+	}
+
+	return nil
+}
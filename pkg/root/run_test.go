@@ -0,0 +1,179 @@
+package root_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRunCommand returns a `root run` command for a root module in a temporary
+// directory, which runs the given script as Terraform.
+func newRunCommand(t *testing.T, terraformScript string) *root.CommandRun {
+	dir := t.TempDir()
+
+	rootModule := filepath.Join(dir, "root")
+	require.NoError(t, os.MkdirAll(rootModule, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(rootModule, "main.tf"), []byte(`resource "null_resource" "a" {}`), 0644))
+
+	terraformBinary := filepath.Join(dir, "bin", "terraform")
+	require.NoError(t, os.MkdirAll(filepath.Dir(terraformBinary), 0750))
+	require.NoError(t, os.WriteFile(terraformBinary, []byte("#!/usr/bin/env bash\n"+terraformScript), 0755))
+
+	return &root.CommandRun{
+		TerraformBinary:   terraformBinary,
+		RootModule:        rootModule,
+		VirtualEnvBaseDir: filepath.Join(dir, "venvs"),
+		PolicyNamespace:   "terraform",
+		PleaseOpts:        &please.Opts{PlzOutDir: "plz-out/"},
+		Opts:              &root.Opts{MetadataFile: ".please/terraform/root.json"},
+	}
+}
+
+func TestCommandRunExecute(t *testing.T) {
+	c := newRunCommand(t, `echo "$@" >> calls.txt`)
+	c.Init = true
+	c.InitArgs = []string{"-backend=false"}
+	c.PreCmds = []string{`echo "pre $(basename "$(command -v terraform)")" >> calls.txt`}
+	c.PostCmds = []string{`echo "post" >> calls.txt`}
+	c.Positional.Args = []string{"plan", "-var=name=has spaces"}
+
+	require.NoError(t, c.Execute(nil))
+
+	calls, err := os.ReadFile(filepath.Join(c.VirtualEnvBaseDir, c.RootModule, "calls.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "pre terraform\ninit -input=false -backend=false\nplan -var=name=has spaces\npost\n", string(calls))
+}
+
+func TestCommandRunExecuteExitCode(t *testing.T) {
+	c := newRunCommand(t, `exit 3`)
+	c.PostCmds = []string{`echo "post" >> calls.txt`}
+	c.Positional.Args = []string{"apply"}
+
+	err := c.Execute(nil)
+	var exitErr *root.ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.NoFileExists(t, filepath.Join(c.VirtualEnvBaseDir, c.RootModule, "calls.txt"))
+}
+
+func TestCommandRunExecuteForwardsSignals(t *testing.T) {
+	c := newRunCommand(t, `trap 'exit 4' TERM
+touch ready
+while true; do sleep 0.1; done`)
+	c.Positional.Args = []string{"apply"}
+
+	go func() {
+		ready := filepath.Join(c.VirtualEnvBaseDir, c.RootModule, "ready")
+		for {
+			if _, err := os.Stat(ready); err == nil {
+				_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	err := c.Execute(nil)
+	var exitErr *root.ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 4, exitErr.ExitCode())
+}
+
+func TestCommandRunExecuteWorkspace(t *testing.T) {
+	c := newRunCommand(t, `echo "$@" >> calls.txt`)
+	m := &root.Metadata{Target: "//my_tf:my_tf", Workspaces: []string{"dev", "prod"}}
+	require.NoError(t, m.Save(filepath.Join(c.RootModule, c.Opts.MetadataFile)))
+	for _, workspace := range []string{"dev", "prod"} {
+		varFile := filepath.Join(c.RootModule, root.WorkspacesDir(c.Opts.MetadataFile), workspace, "workspace-0-"+workspace+".auto.tfvars")
+		require.NoError(t, os.MkdirAll(filepath.Dir(varFile), 0750))
+		require.NoError(t, os.WriteFile(varFile, []byte(`name = "`+workspace+`"`), 0644))
+	}
+	c.Init = true
+	c.Workspace = "dev"
+	c.Positional.Args = []string{"plan"}
+
+	require.NoError(t, c.Execute(nil))
+
+	virtualEnvDir := filepath.Join(c.VirtualEnvBaseDir, c.RootModule)
+	calls, err := os.ReadFile(filepath.Join(virtualEnvDir, "calls.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "init -input=false\nworkspace select -or-create dev\nplan\n", string(calls))
+	assert.FileExists(t, filepath.Join(virtualEnvDir, "workspace-0-dev.auto.tfvars"))
+	assert.NoFileExists(t, filepath.Join(virtualEnvDir, "workspace-0-prod.auto.tfvars"))
+
+	c.Workspace = "staging"
+	assert.ErrorContains(t, c.Execute(nil), "workspace 'staging' is not one of the root's workspaces: dev, prod")
+}
+
+func TestCommandRunExecuteApplyWithPolicies(t *testing.T) {
+	terraformScript := `echo "$@" >> calls.txt
+if [[ "$1" == "show" ]]; then
+  echo '{"resource_changes": []}'
+fi`
+	policies := filepath.Join(t.TempDir(), "policies")
+	require.NoError(t, os.MkdirAll(policies, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(policies, "delete.rego"), []byte(`package terraform
+
+deny[{"msg": msg}] {
+	input.change.actions[_] == "delete"
+	msg := "resources must not be deleted"
+}
+`), 0644))
+
+	var tests = []struct {
+		description string
+		args        []string
+		expected    string
+	}{
+		{
+			"values after =",
+			[]string{"apply", "-var=name=network", "-target=null_resource.a", "-auto-approve", "-parallelism=2"},
+			`plan -out=please.tfplan -var=name=network -target=null_resource.a -parallelism=2
+show -json please.tfplan
+apply -input=false -auto-approve -parallelism=2 please.tfplan
+`,
+		},
+		{
+			"values as the next argument",
+			[]string{"apply", "-var", "name=network", "-var-file", "extra.tfvars", "-target", "null_resource.a", "-auto-approve", "-backup", "backup.tfstate", "--parallelism", "2"},
+			`plan -out=please.tfplan -var name=network -var-file extra.tfvars -target null_resource.a --parallelism 2
+show -json please.tfplan
+apply -input=false -auto-approve -backup backup.tfstate --parallelism 2 please.tfplan
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			c := newRunCommand(t, terraformScript)
+			c.Policies = []string{policies}
+			c.Positional.Args = tt.args
+
+			require.NoError(t, c.Execute(nil))
+
+			calls, err := os.ReadFile(filepath.Join(c.VirtualEnvBaseDir, c.RootModule, "calls.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(calls))
+		})
+	}
+}
+
+func TestCommandRunExecuteShell(t *testing.T) {
+	c := newRunCommand(t, `echo "$@" >> calls.txt`)
+	c.Shell = true
+	c.Positional.Args = []string{"terraform init && terraform console"}
+
+	require.NoError(t, c.Execute(nil))
+
+	calls, err := os.ReadFile(filepath.Join(c.VirtualEnvBaseDir, c.RootModule, "calls.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "init\nconsole\n", string(calls))
+}
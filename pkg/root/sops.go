@@ -0,0 +1,120 @@
+package root
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/sops"
+)
+
+const (
+	// SOPSTFVarsSuffix is the suffix of var files encrypted by SOPS.
+	SOPSTFVarsSuffix = ".sops.tfvars"
+	// SOPSTFVarsJSONSuffix is the suffix of JSON var files encrypted by SOPS.
+	SOPSTFVarsJSONSuffix = ".sops.tfvars.json"
+)
+
+// IsSOPSVarFile returns whether the given var file is encrypted by SOPS,
+// according to its name.
+func IsSOPSVarFile(name string) bool {
+	return strings.HasSuffix(name, SOPSTFVarsSuffix) || strings.HasSuffix(name, SOPSTFVarsJSONSuffix)
+}
+
+// checkEncrypted fails if the given var file is named as encrypted by SOPS but
+// is not, so that plaintext secrets are not added to a cacheable root.
+func checkEncrypted(varFile string) error {
+	if !IsSOPSVarFile(varFile) {
+		return nil
+	}
+
+	data, err := os.ReadFile(varFile)
+	if err != nil {
+		return fmt.Errorf("could not read var file '%s': %w", varFile, err)
+	}
+	if !sops.IsEncrypted(data) {
+		return fmt.Errorf("var file '%s' is not encrypted by SOPS, refusing to add it to the root", varFile)
+	}
+
+	return nil
+}
+
+// DecryptVarFiles decrypts the SOPS encrypted var files in the given virtual
+// env so that Terraform auto-loads them. The plaintext is only written to
+// virtual envs outside of Please's cacheable outputs.
+func DecryptVarFiles(repoRoot string, plzOutDir string, virtualEnvDir string) error {
+	entries, err := os.ReadDir(virtualEnvDir)
+	if err != nil {
+		return fmt.Errorf("could not read directory '%s': %w", virtualEnvDir, err)
+	}
+
+	varFiles := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && IsSOPSVarFile(entry.Name()) {
+			varFiles = append(varFiles, entry.Name())
+		}
+	}
+	if len(varFiles) == 0 {
+		return nil
+	}
+
+	if err := checkNotCacheable(repoRoot, plzOutDir, virtualEnvDir); err != nil {
+		return err
+	}
+
+	identities, err := sops.Identities()
+	if err != nil {
+		return err
+	}
+
+	for _, varFile := range varFiles {
+		path := filepath.Join(virtualEnvDir, varFile)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read '%s': %w", path, err)
+		}
+
+		plaintext, err := sops.Decrypt(data, sops.FormatOf(varFile), identities)
+		if err != nil {
+			return fmt.Errorf("could not decrypt '%s': %w", varFile, err)
+		}
+
+		newName := DecryptedTFVarsName(varFile)
+		if err := os.WriteFile(filepath.Join(virtualEnvDir, newName), plaintext, 0600); err != nil {
+			return fmt.Errorf("could not write decrypted var file '%s': %w", newName, err)
+		}
+		log.Debug().Str("encrypted_tfvars_name", varFile).Str("new_tfvars_name", newName).Msg("decrypted tfvars")
+	}
+
+	return nil
+}
+
+// DecryptedTFVarsName returns the name of the tfvars file that will be
+// automatically loaded by Terraform for the given SOPS encrypted var file.
+func DecryptedTFVarsName(varFile string) string {
+	if strings.HasSuffix(varFile, SOPSTFVarsJSONSuffix) {
+		return strings.TrimSuffix(varFile, SOPSTFVarsJSONSuffix) + ".auto.tfvars.json"
+	}
+
+	return strings.TrimSuffix(varFile, SOPSTFVarsSuffix) + ".auto.tfvars"
+}
+
+// checkNotCacheable fails if the given directory is in one of the directories
+// of plz-out which Please caches, or builds them in.
+func checkNotCacheable(repoRoot string, plzOutDir string, dir string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("could not get absolute path for '%s': %w", dir, err)
+	}
+
+	for _, cacheable := range []string{"gen", "bin", "tmp"} {
+		cacheableDir := filepath.Join(repoRoot, plzOutDir, cacheable)
+		rel, err := filepath.Rel(cacheableDir, absDir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("refusing to decrypt var files into '%s' as it is in the cacheable '%s'", dir, cacheableDir)
+		}
+	}
+
+	return nil
+}
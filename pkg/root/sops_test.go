@@ -0,0 +1,32 @@
+package root_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptedTFVarsName(t *testing.T) {
+	assert.Equal(t, "0-secrets.auto.tfvars", root.DecryptedTFVarsName("0-secrets.sops.tfvars"))
+	assert.Equal(t, "workspace-1-secrets.auto.tfvars.json", root.DecryptedTFVarsName("workspace-1-secrets.sops.tfvars.json"))
+}
+
+func TestDecryptVarFilesRefusesCacheableDirs(t *testing.T) {
+	repoRoot := t.TempDir()
+	varFile := `{"data": "ENC[AES256_GCM,data:,iv:,tag:,type:str]", "sops": {"mac": "ENC[AES256_GCM,data:,iv:,tag:,type:str]"}}`
+
+	genDir := filepath.Join(repoRoot, "plz-out", "gen", "my_tf", "my_tf_root")
+	require.NoError(t, os.MkdirAll(genDir, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(genDir, "0-secrets.sops.tfvars"), []byte(varFile), 0644))
+	assert.ErrorContains(t, root.DecryptVarFiles(repoRoot, "plz-out", genDir), "refusing to decrypt var files into")
+
+	// Virtual envs without encrypted var files need no age keys.
+	venvDir := filepath.Join(repoRoot, "plz-out", "terraform", "venvs", "my_tf")
+	require.NoError(t, os.MkdirAll(venvDir, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(venvDir, "0-vars.auto.tfvars"), []byte(`name = "a"`), 0644))
+	assert.NoError(t, root.DecryptVarFiles(repoRoot, "plz-out", venvDir))
+}
@@ -0,0 +1,133 @@
+package root
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// ExitError represents an error which should cause please_terraform to exit
+// with a specific exit code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code that please_terraform should exit with.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// runTerraform runs Terraform with the given arguments in the given directory,
+// writing its output to stderr. It returns Terraform's exit code alongside
+// an error if Terraform could not be run.
+func runTerraform(terraformBinary string, dir string, args ...string) (int, error) {
+	return runTerraformTo(os.Stderr, terraformBinary, dir, args...)
+}
+
+// runTerraformTo is runTerraform but writes Terraform's stdout to the given
+// writer.
+func runTerraformTo(stdout io.Writer, terraformBinary string, dir string, args ...string) (int, error) {
+	cmd := exec.Command(terraformBinary, args...)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+
+	log.Debug().Str("dir", dir).Strs("args", args).Msg("running terraform")
+	exitCode, err := runForwardingSignals(cmd)
+	if err != nil {
+		return exitCode, fmt.Errorf("could not run 'terraform %s': %w", strings.Join(args, " "), err)
+	}
+
+	return exitCode, nil
+}
+
+// runForwardingSignals runs the given command, forwarding the interrupt and
+// termination signals which please_terraform receives to it so that it can
+// exit gracefully, e.g. so that Terraform releases its state lock. It returns
+// the command's exit code alongside an error if it could not be run.
+func runForwardingSignals(cmd *exec.Cmd) (int, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return 1, err
+	}
+
+	exited := make(chan struct{})
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		for {
+			select {
+			case sig := <-signals:
+				// A terminal sends Ctrl-C to its whole foreground process
+				// group, which the command is already in. Terraform exits
+				// immediately on a second interrupt, so it is not forwarded.
+				if sig == os.Interrupt && stdinIsTerminal() {
+					continue
+				}
+				log.Debug().Str("signal", sig.String()).Msg("forwarding signal")
+				_ = cmd.Process.Signal(sig)
+			case <-exited:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	close(exited)
+	<-forwarded
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, err
+	}
+
+	return 0, nil
+}
+
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// terraformOutput runs Terraform with the given arguments in the given
+// directory and returns what it writes to stdout.
+func terraformOutput(terraformBinary string, dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command(terraformBinary, args...)
+	cmd.Dir = dir
+	stdout := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+
+	log.Debug().Str("dir", dir).Strs("args", args).Msg("running terraform")
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("could not run 'terraform %s': %w", strings.Join(args, " "), err)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,312 @@
+package root
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+)
+
+// CommandTest represents the test subcommand.
+type CommandTest struct {
+	TerraformBinary string `long:"terraform_binary" default:"terraform" description:"The Terraform binary to run the tests with."`
+	Module          string `long:"module" required:"true" description:"The built Terraform root or module to test."`
+	ResultsFile     string `long:"results_file" env:"RESULTS_FILE" default:"test.results" description:"The file to write the JUnit XML test results to."`
+
+	Positional struct {
+		Tests []string `positional-arg-name:"tests" description:"Terraform test files (.tftest.hcl) to run alongside any in the module."`
+	} `positional-args:"yes"`
+}
+
+// Execute runs `terraform test` against a copy of the configured module in an
+// isolated virtual env and writes the results in a format `plz test`
+// understands.
+func (c *CommandTest) Execute(args []string) error {
+	terraformBinary, err := absIfPath(c.TerraformBinary)
+	if err != nil {
+		return err
+	}
+
+	virtualEnvDir, err := os.MkdirTemp("", "please-terraform-test-")
+	if err != nil {
+		return fmt.Errorf("could not create virtual env dir: %w", err)
+	}
+	defer os.RemoveAll(virtualEnvDir)
+
+	if err := SyncVirtualEnv(c.Module, virtualEnvDir); err != nil {
+		return err
+	}
+	for _, test := range c.Positional.Tests {
+		if err := please.CopyFile(test, filepath.Join(virtualEnvDir, filepath.Base(test))); err != nil {
+			return fmt.Errorf("could not copy test file '%s': %w", test, err)
+		}
+	}
+
+	code, err := runTerraform(terraformBinary, virtualEnvDir, "init", "-backend=false", "-input=false")
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &ExitError{Code: code, Err: fmt.Errorf("terraform init exited with %d", code)}
+	}
+
+	stdout := &bytes.Buffer{}
+	code, err = runTerraformTo(stdout, terraformBinary, virtualEnvDir, "test", "-json")
+	if err != nil {
+		return err
+	}
+
+	results, err := ParseTestResults(stdout)
+	if err != nil {
+		return err
+	}
+
+	resultsFile, err := os.Create(c.ResultsFile)
+	if err != nil {
+		return fmt.Errorf("could not create results file '%s': %w", c.ResultsFile, err)
+	}
+	defer resultsFile.Close()
+	if err := results.WriteJUnit(resultsFile); err != nil {
+		return fmt.Errorf("could not write results file '%s': %w", c.ResultsFile, err)
+	}
+
+	for _, file := range results.Files {
+		for _, run := range file.Runs {
+			log.Info().Str("file", file.Path).Str("run", run.Name).Str("status", run.Status).Msg("test run")
+		}
+	}
+
+	if code != 0 {
+		return &ExitError{Code: code, Err: fmt.Errorf("terraform test exited with %d", code)}
+	}
+
+	return nil
+}
+
+// absIfPath returns the absolute path of the given binary if it is a path
+// rather than a name to look up in PATH, as Terraform is run in another
+// directory.
+func absIfPath(binary string) (string, error) {
+	if !strings.ContainsRune(binary, filepath.Separator) {
+		return binary, nil
+	}
+
+	return filepath.Abs(binary)
+}
+
+// TestResults represents the results of `terraform test`.
+type TestResults struct {
+	Files []*TestFile
+}
+
+// TestFile represents the results of a Terraform test file.
+type TestFile struct {
+	Path   string
+	Status string
+	// Diagnostics are the diagnostics which do not belong to a run.
+	Diagnostics []string
+	Runs        []*TestRun
+}
+
+// TestRun represents the result of a `run` block in a Terraform test file.
+type TestRun struct {
+	Name        string
+	Status      string
+	Elapsed     float64
+	Diagnostics []string
+}
+
+// testMessage represents a line of `terraform test -json` output.
+type testMessage struct {
+	Type     string `json:"type"`
+	TestFile string `json:"@testfile"`
+	TestRun  string `json:"@testrun"`
+
+	File *struct {
+		Path   string `json:"path"`
+		Status string `json:"status"`
+	} `json:"test_file"`
+	Run *struct {
+		Path     string `json:"path"`
+		Run      string `json:"run"`
+		Status   string `json:"status"`
+		Progress string `json:"progress"`
+		Elapsed  int64  `json:"elapsed"`
+	} `json:"test_run"`
+	Diagnostic *struct {
+		Severity string `json:"severity"`
+		Summary  string `json:"summary"`
+		Detail   string `json:"detail"`
+	} `json:"diagnostic"`
+}
+
+// ParseTestResults parses the machine-readable output of `terraform test -json`.
+func ParseTestResults(r io.Reader) (*TestResults, error) {
+	results := &TestResults{Files: []*TestFile{}}
+	files := map[string]*TestFile{}
+	getFile := func(path string) *TestFile {
+		if _, ok := files[path]; !ok {
+			files[path] = &TestFile{Path: path, Runs: []*TestRun{}}
+			results.Files = append(results.Files, files[path])
+		}
+		return files[path]
+	}
+	getRun := func(file *TestFile, name string) *TestRun {
+		for _, run := range file.Runs {
+			if run.Name == name {
+				return run
+			}
+		}
+		run := &TestRun{Name: name}
+		file.Runs = append(file.Runs, run)
+		return run
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		msg := &testMessage{}
+		if err := json.Unmarshal(line, msg); err != nil {
+			return nil, fmt.Errorf("could not parse terraform test output '%s': %w", line, err)
+		}
+
+		switch {
+		case msg.Type == "test_file" && msg.File != nil:
+			file := getFile(msg.File.Path)
+			if msg.File.Status != "" {
+				file.Status = msg.File.Status
+			}
+		case msg.Type == "test_run" && msg.Run != nil:
+			run := getRun(getFile(msg.Run.Path), msg.Run.Run)
+			// Terraform 1.7+ reports the progress of runs, only the
+			// completed run has a meaningful status.
+			if msg.Run.Status != "" && (msg.Run.Progress == "" || msg.Run.Progress == "complete") {
+				run.Status = msg.Run.Status
+			}
+			if msg.Run.Elapsed > 0 {
+				run.Elapsed = float64(msg.Run.Elapsed) / 1000
+			}
+		case msg.Type == "diagnostic" && msg.Diagnostic != nil && msg.TestFile != "":
+			diagnostic := fmt.Sprintf("%s: %s", msg.Diagnostic.Severity, msg.Diagnostic.Summary)
+			if msg.Diagnostic.Detail != "" {
+				diagnostic += "\n" + msg.Diagnostic.Detail
+			}
+			file := getFile(msg.TestFile)
+			if msg.TestRun == "" {
+				file.Diagnostics = append(file.Diagnostics, diagnostic)
+				continue
+			}
+			run := getRun(file, msg.TestRun)
+			run.Diagnostics = append(run.Diagnostics, diagnostic)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read terraform test output: %w", err)
+	}
+
+	return results, nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Cases    []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr,omitempty"`
+	Contents string `xml:",chardata"`
+}
+
+// WriteJUnit writes the test results as JUnit XML, with a test suite per
+// test file and a test case per run.
+func (r *TestResults) WriteJUnit(w io.Writer) error {
+	suites := &junitTestSuites{Suites: []*junitTestSuite{}}
+	for _, file := range r.Files {
+		suite := &junitTestSuite{Name: file.Path, Cases: []*junitTestCase{}}
+		for _, run := range file.Runs {
+			testCase := &junitTestCase{
+				Name:      run.Name,
+				ClassName: file.Path,
+				Time:      run.Elapsed,
+			}
+			message := &junitMessage{
+				Message:  fmt.Sprintf("run %q %s", run.Name, run.Status),
+				Contents: strings.Join(run.Diagnostics, "\n\n"),
+			}
+			switch run.Status {
+			case "fail":
+				testCase.Failure = message
+				suite.Failures++
+			case "error":
+				testCase.Error = message
+				suite.Errors++
+			case "skip":
+				testCase.Skipped = message
+				suite.Skipped++
+			}
+			suite.Tests++
+			suite.Time += run.Elapsed
+			suite.Cases = append(suite.Cases, testCase)
+		}
+
+		// Errors outside of runs, e.g. an invalid test file, would otherwise
+		// go unreported.
+		if len(file.Diagnostics) > 0 || (file.Status == "error" && len(file.Runs) == 0) {
+			suite.Cases = append(suite.Cases, &junitTestCase{
+				Name:      file.Path,
+				ClassName: file.Path,
+				Error: &junitMessage{
+					Message:  fmt.Sprintf("file %q %s", file.Path, file.Status),
+					Contents: strings.Join(file.Diagnostics, "\n\n"),
+				},
+			})
+			suite.Tests++
+			suite.Errors++
+		}
+
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
@@ -0,0 +1,109 @@
+package root_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTestResults(t *testing.T) {
+	f, err := os.Open("testdata/terraform_test.jsonl")
+	require.NoError(t, err)
+	defer f.Close()
+
+	results, err := root.ParseTestResults(f)
+	require.NoError(t, err)
+
+	assert.Equal(t, &root.TestResults{Files: []*root.TestFile{
+		{
+			Path:   "main.tftest.hcl",
+			Status: "fail",
+			Runs: []*root.TestRun{
+				{Name: "defaults", Status: "pass", Elapsed: 1.5},
+				{Name: "override", Status: "fail", Elapsed: 0.25, Diagnostics: []string{
+					"error: Test assertion failed\nname did not match expected value",
+				}},
+			},
+		},
+		{
+			Path:        "invalid.tftest.hcl",
+			Status:      "error",
+			Runs:        []*root.TestRun{},
+			Diagnostics: []string{"error: Unsupported block type"},
+		},
+	}}, results)
+
+	junit := &bytes.Buffer{}
+	require.NoError(t, results.WriteJUnit(junit))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="main.tftest.hcl" tests="2" failures="1" errors="0" skipped="0" time="1.75">
+    <testcase name="defaults" classname="main.tftest.hcl" time="1.5"></testcase>
+    <testcase name="override" classname="main.tftest.hcl" time="0.25">
+      <failure message="run &#34;override&#34; fail">error: Test assertion failed&#xA;name did not match expected value</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="invalid.tftest.hcl" tests="1" failures="0" errors="1" skipped="0" time="0">
+    <testcase name="invalid.tftest.hcl" classname="invalid.tftest.hcl" time="0">
+      <error message="file &#34;invalid.tftest.hcl&#34; error">error: Unsupported block type</error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, junit.String())
+}
+
+func TestCommandTest(t *testing.T) {
+	output, err := filepath.Abs("testdata/terraform_test.jsonl")
+	require.NoError(t, err)
+
+	// A fake Terraform which records its working directory and arguments.
+	dir := t.TempDir()
+	terraformBinary := filepath.Join(dir, "terraform")
+	require.NoError(t, os.WriteFile(terraformBinary, []byte(fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %[1]s/calls
+ls >> %[1]s/calls
+if [ "$1" = "test" ]; then
+  cat %[2]s
+  exit 1
+fi
+`, dir, output)), 0755))
+
+	module := filepath.Join(dir, "module")
+	require.NoError(t, os.MkdirAll(module, 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(module, "main.tf"), []byte(""), 0644))
+	testFile := filepath.Join(dir, "main.tftest.hcl")
+	require.NoError(t, os.WriteFile(testFile, []byte(""), 0644))
+
+	c := &root.CommandTest{
+		TerraformBinary: terraformBinary,
+		Module:          module,
+		ResultsFile:     filepath.Join(dir, "test.results"),
+	}
+	c.Positional.Tests = []string{testFile}
+
+	err = c.Execute(nil)
+	var exitErr *root.ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 1, exitErr.ExitCode())
+
+	calls, err := os.ReadFile(filepath.Join(dir, "calls"))
+	require.NoError(t, err)
+	assert.Equal(t, `init -backend=false -input=false
+main.tf
+main.tftest.hcl
+test -json
+main.tf
+main.tftest.hcl
+`, string(calls))
+
+	results, err := os.ReadFile(c.ResultsFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(results), `<testcase name="defaults" classname="main.tftest.hcl" time="1.5"></testcase>`)
+}
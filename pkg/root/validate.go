@@ -0,0 +1,99 @@
+package root
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+	"github.com/hashicorp/hcl/v2"
+)
+
+const (
+	// VarFileValidationOff does not validate the var files of roots.
+	VarFileValidationOff = "off"
+	// VarFileValidationWarn logs the problems with the var files of roots.
+	VarFileValidationWarn = "warn"
+	// VarFileValidationError fails to build roots with problems with their
+	// var files.
+	VarFileValidationError = "error"
+)
+
+// ValidateVarFiles returns the problems with the var files of the given built
+// root against the given variables it declares, with their positions in the
+// var files and srcs relative to the repository root. The var files of each
+// of the root's workspaces are validated together with the root's own. SOPS
+// encrypted var files cannot be read when building, so they are skipped, as
+// are required variables where they might give a value.
+func ValidateVarFiles(out string, metadataFile string, m *Metadata, variables []*tfconfig.Variable) (hcl.Diagnostics, error) {
+	workspaces := m.Workspaces
+	if len(workspaces) == 0 {
+		workspaces = []string{""}
+	}
+
+	var diags hcl.Diagnostics
+	checked := map[string][]*tfconfig.VarAssignment{}
+	for _, workspace := range workspaces {
+		varFiles, err := loadOrderedVarFiles(out, metadataFile, m, workspace)
+		if err != nil {
+			return nil, err
+		}
+
+		assignments := []*tfconfig.VarAssignment{}
+		encrypted := false
+		for _, varFile := range varFiles {
+			if varFile.encrypted {
+				log.Debug().Str("var_file", varFile.source).Msg("not validating encrypted var file")
+				encrypted = true
+				continue
+			}
+
+			fileAssignments, ok := checked[varFile.path]
+			if !ok {
+				data, err := os.ReadFile(varFile.path)
+				if err != nil {
+					return nil, fmt.Errorf("could not read var file '%s': %w", varFile.path, err)
+				}
+				fileAssignments, err = tfconfig.LoadVarFile(varFile.source, data)
+				if fileDiags, isDiags := err.(hcl.Diagnostics); isDiags {
+					diags = append(diags, fileDiags...)
+				} else if err != nil {
+					return nil, err
+				}
+				diags = append(diags, tfconfig.CheckVarAssignments(variables, fileAssignments)...)
+				checked[varFile.path] = fileAssignments
+			}
+			assignments = append(assignments, fileAssignments...)
+		}
+
+		if encrypted {
+			continue
+		}
+		for _, diag := range tfconfig.CheckRequiredVariables(variables, assignments) {
+			if workspace != "" {
+				diag.Detail += fmt.Sprintf(" It has no value in workspace %q.", workspace)
+			}
+			diags = append(diags, diag)
+		}
+	}
+
+	// Report the positions of variables in the srcs they were copied from.
+	srcs := map[string]string{}
+	for _, src := range m.Srcs {
+		srcs[filepath.Base(src)] = src
+	}
+	for _, diag := range diags {
+		if diag.Subject == nil {
+			continue
+		}
+		if filepath.Dir(diag.Subject.Filename) != filepath.Clean(out) {
+			continue
+		}
+		if src, ok := srcs[filepath.Base(diag.Subject.Filename)]; ok {
+			diag.Subject.Filename = src
+		}
+	}
+	tfconfig.SortDiagnostics(diags)
+
+	return diags, nil
+}
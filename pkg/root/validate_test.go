@@ -0,0 +1,64 @@
+package root_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateVarFiles(t *testing.T) {
+	out := t.TempDir()
+	metadataFile := ".please/terraform/root.json"
+	m := &root.Metadata{
+		Target:   "//infra/network:network",
+		Srcs:     []string{"infra/network/variables.tf"},
+		VarFiles: []string{"infra/common.tfvars"},
+		WorkspaceVarFiles: map[string][]string{
+			"dev":  {"infra/network/dev.tfvars"},
+			"prod": {"infra/network/prod.sops.tfvars"},
+		},
+		Workspaces: []string{"dev", "prod", "staging"},
+	}
+
+	files := map[string]string{
+		"variables.tf": `
+variable "region" {
+  type = string
+}
+
+variable "replicas" {
+  type = number
+}
+`,
+		"0-common.auto.tfvars": "region = \"eu-west-1\"\nowner = \"network\"\n",
+		".please/terraform/workspaces/dev/workspace-0-dev.auto.tfvars":   `replicas = "many"`,
+		".please/terraform/workspaces/prod/workspace-0-prod.sops.tfvars": `{"data": "ENC[AES256_GCM,data:,iv:,tag:,type:str]", "sops": {}}`,
+	}
+	for name, contents := range files {
+		path := filepath.Join(out, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+
+	cfg, err := tfconfig.LoadDir(out)
+	require.NoError(t, err)
+
+	diags, err := root.ValidateVarFiles(out, metadataFile, m, cfg.Variables)
+	require.NoError(t, err)
+
+	actual := []string{}
+	for _, diag := range diags {
+		actual = append(actual, diag.Error())
+	}
+	// The encrypted var file of prod may give the required variable a value.
+	assert.Equal(t, []string{
+		`infra/common.tfvars:2,1-18: Value for undeclared variable; The root does not declare a variable named "owner".`,
+		`infra/network/dev.tfvars:1,1-18: Invalid value for variable; The value for variable "replicas" does not conform to its type constraint number: a number is required.`,
+		`infra/network/variables.tf:6,1-20: No value for required variable; The root requires a value for variable "replicas", which has no default and is not given by a var file. It has no value in workspace "staging".`,
+	}, actual)
+}
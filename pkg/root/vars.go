@@ -0,0 +1,295 @@
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"filippo.io/age"
+	"github.com/VJftw/please-terraform/pkg/sops"
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+)
+
+// DefaultSource is the source of variable values which come from the
+// variable's default.
+const DefaultSource = "default"
+
+// CommandVars represents the `root vars` command and its flags.
+type CommandVars struct {
+	RootModule string `long:"root_module" required:"true" description:"The built Terraform root to report the variables of."`
+	Workspace  string `long:"workspace" env:"PLEASE_TERRAFORM_WORKSPACE" description:"The Terraform workspace to include the var files of."`
+	Format     string `long:"format" default:"table" choice:"table" choice:"json" description:"The format to report variables in."`
+
+	Opts *Opts
+}
+
+// VarReport represents the effective value of a variable of a Terraform root
+// and the values which it overrides.
+type VarReport struct {
+	Name      string `json:"name"`
+	Sensitive bool   `json:"sensitive"`
+	// Undeclared is whether the variable is given a value but is not declared
+	// by the root, which Terraform warns about.
+	Undeclared bool `json:"undeclared,omitempty"`
+	*VarValue
+	// Overridden are the values given to the variable before its effective
+	// value, in load order.
+	Overridden []*VarValue `json:"overridden,omitempty"`
+}
+
+// VarValue represents a value given to a variable and where it came from.
+type VarValue struct {
+	Value interface{} `json:"value"`
+	// Source is the var file, relative to the repository root, or the
+	// DefaultSource that the value came from.
+	Source string `json:"source"`
+}
+
+// Execute reports the effective value of each variable of the configured
+// Terraform root.
+func (c *CommandVars) Execute(args []string) error {
+	reports, err := NewVarReports(c.RootModule, c.Opts.MetadataFile, c.Workspace)
+	if err != nil {
+		return err
+	}
+
+	switch c.Format {
+	case "json":
+		reportsBytes, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not marshal variable report: %w", err)
+		}
+		fmt.Println(string(reportsBytes))
+	default:
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tVALUE\tSOURCE\tOVERRIDES")
+		for _, report := range reports {
+			value := "(unset)"
+			source := ""
+			if report.VarValue != nil {
+				value = formatVarValue(report.Value)
+				source = report.Source
+			}
+			overrides := make([]string, 0, len(report.Overridden))
+			for _, overridden := range report.Overridden {
+				overrides = append(overrides, fmt.Sprintf("%s (%s)", formatVarValue(overridden.Value), overridden.Source))
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", report.Name, value, source, strings.Join(overrides, ", "))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatVarValue returns the given variable value as it is written in
+// tfvars, except for masked values.
+func formatVarValue(value interface{}) string {
+	if value == SensitiveValue {
+		return SensitiveValue
+	}
+
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(valueBytes)
+}
+
+// NewVarReports returns the effective value of each variable of the given
+// built Terraform root, sorted by name. Var files are considered in the order
+// that Terraform loads them, including those of the given workspace if any.
+// The values of sensitive variables, and of those given by SOPS encrypted
+// var files, are masked.
+func NewVarReports(rootModule string, metadataFile string, workspace string) ([]*VarReport, error) {
+	m, err := LoadMetadata(filepath.Join(rootModule, metadataFile))
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := tfconfig.LoadDir(rootModule)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := map[string]*VarReport{}
+	for _, variable := range config.Variables {
+		report := &VarReport{Name: variable.Name, Sensitive: variable.Sensitive}
+		if !variable.Required {
+			report.VarValue = &VarValue{Value: variable.Default, Source: DefaultSource}
+		}
+		reports[variable.Name] = report
+	}
+
+	varFiles, err := loadOrderedVarFiles(rootModule, metadataFile, m, workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	var identities []age.Identity
+	for _, varFile := range varFiles {
+		data, err := os.ReadFile(varFile.path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read var file '%s': %w", varFile.path, err)
+		}
+		if varFile.encrypted {
+			if identities == nil {
+				if identities, err = sops.Identities(); err != nil {
+					return nil, err
+				}
+			}
+			if data, err = sops.Decrypt(data, sops.FormatOf(varFile.path), identities); err != nil {
+				return nil, fmt.Errorf("could not decrypt '%s': %w", varFile.source, err)
+			}
+		}
+
+		assignments, err := tfconfig.LoadVarFile(varFile.name, data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse var file '%s': %w", varFile.source, err)
+		}
+
+		for _, assignment := range assignments {
+			report, ok := reports[assignment.Name]
+			if !ok {
+				log.Warn().Str("variable", assignment.Name).Str("var_file", varFile.source).Msg("value given for undeclared variable")
+				report = &VarReport{Name: assignment.Name, Undeclared: true}
+				reports[assignment.Name] = report
+			}
+			if report.VarValue != nil {
+				report.Overridden = append(report.Overridden, report.VarValue)
+			}
+			value := assignment.Value
+			if varFile.encrypted {
+				value = SensitiveValue
+			}
+			report.VarValue = &VarValue{Value: value, Source: varFile.source}
+		}
+	}
+
+	sorted := make([]*VarReport, 0, len(reports))
+	for _, report := range reports {
+		if report.Sensitive {
+			maskVarValue(report.VarValue)
+			for _, overridden := range report.Overridden {
+				maskVarValue(overridden)
+			}
+		}
+		sorted = append(sorted, report)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	return sorted, nil
+}
+
+func maskVarValue(value *VarValue) {
+	if value != nil && value.Value != nil {
+		value.Value = SensitiveValue
+	}
+}
+
+// varFile represents a var file which Terraform auto-loads in a root's
+// virtual env.
+type varFile struct {
+	// name is the name that Terraform loads the var file by.
+	name string
+	// path is the path to the var file in the built root.
+	path string
+	// source is the var file relative to the repository root, if known.
+	source    string
+	encrypted bool
+}
+
+// loadOrderedVarFiles returns the var files of the given built Terraform root,
+// and of the given workspace, in the order that Terraform loads them:
+// `terraform.tfvars`, `terraform.tfvars.json` and then `*.auto.tfvars` and
+// `*.auto.tfvars.json` in lexical order.
+func loadOrderedVarFiles(rootModule string, metadataFile string, m *Metadata, workspace string) ([]*varFile, error) {
+	sources := map[string]string{}
+	for _, src := range m.Srcs {
+		sources[filepath.Base(src)] = src
+	}
+	for i, src := range m.VarFiles {
+		name, err := AutoTFVarsName(i, src)
+		if err != nil {
+			return nil, err
+		}
+		sources[name] = src
+	}
+	for i, src := range m.WorkspaceVarFiles[workspace] {
+		name, err := WorkspaceTFVarsName(i, src)
+		if err != nil {
+			return nil, err
+		}
+		sources[name] = src
+	}
+
+	dirs := []string{rootModule}
+	if workspace != "" {
+		dirs = append(dirs, filepath.Join(rootModule, WorkspacesDir(metadataFile), workspace))
+	}
+
+	varFiles := []*varFile{}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read directory '%s': %w", dir, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			f := &varFile{
+				name:      entry.Name(),
+				path:      filepath.Join(dir, entry.Name()),
+				source:    entry.Name(),
+				encrypted: IsSOPSVarFile(entry.Name()),
+			}
+			if source, ok := sources[entry.Name()]; ok {
+				f.source = source
+			}
+			if f.encrypted {
+				f.name = DecryptedTFVarsName(f.name)
+			}
+			if varFileLoadRank(f.name) < 0 {
+				continue
+			}
+			varFiles = append(varFiles, f)
+		}
+	}
+
+	sort.SliceStable(varFiles, func(i, j int) bool {
+		rankI, rankJ := varFileLoadRank(varFiles[i].name), varFileLoadRank(varFiles[j].name)
+		if rankI != rankJ {
+			return rankI < rankJ
+		}
+		return varFiles[i].name < varFiles[j].name
+	})
+
+	return varFiles, nil
+}
+
+// varFileLoadRank returns the rank of the given var file name in Terraform's
+// load order, or -1 if Terraform does not auto-load it.
+func varFileLoadRank(name string) int {
+	switch {
+	case name == "terraform.tfvars":
+		return 0
+	case name == "terraform.tfvars.json":
+		return 1
+	case strings.HasSuffix(name, ".auto.tfvars"), strings.HasSuffix(name, ".auto.tfvars.json"):
+		return 2
+	}
+
+	return -1
+}
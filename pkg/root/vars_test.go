@@ -0,0 +1,90 @@
+package root_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVarReports(t *testing.T) {
+	rootModule := t.TempDir()
+	metadataFile := ".please/terraform/root.json"
+	require.NoError(t, (&root.Metadata{
+		Target:            "//infra/network:network",
+		Srcs:              []string{"infra/network/variables.tf", "infra/network/terraform.tfvars"},
+		VarFiles:          []string{"infra/common.tfvars", "infra/network/network.tfvars.json"},
+		WorkspaceVarFiles: map[string][]string{"prod": {"infra/network/prod.tfvars"}},
+	}).Save(filepath.Join(rootModule, metadataFile)))
+
+	files := map[string]string{
+		"variables.tf": `
+variable "region" {
+  default = "eu-west-1"
+}
+
+variable "replicas" {
+  type = number
+}
+
+variable "password" {
+  sensitive = true
+}
+
+variable "tags" {
+  default = {}
+}
+`,
+		"terraform.tfvars":           `replicas = 1`,
+		"0-common.auto.tfvars":       "region = \"eu-west-2\"\npassword = \"hunter2\"\nowner = \"network\"\n",
+		"1-network.auto.tfvars.json": `{"replicas": 2, "tags": {"team": "network"}}`,
+		".please/terraform/workspaces/prod/workspace-0-prod.auto.tfvars": `replicas = 3`,
+		"README.md": `# network`,
+	}
+	for name, contents := range files {
+		path := filepath.Join(rootModule, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+
+	reports, err := root.NewVarReports(rootModule, metadataFile, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, []*root.VarReport{
+		{
+			Name:       "owner",
+			Undeclared: true,
+			VarValue:   &root.VarValue{Value: "network", Source: "infra/common.tfvars"},
+		},
+		{
+			Name:      "password",
+			Sensitive: true,
+			VarValue:  &root.VarValue{Value: root.SensitiveValue, Source: "infra/common.tfvars"},
+		},
+		{
+			Name:       "region",
+			VarValue:   &root.VarValue{Value: "eu-west-2", Source: "infra/common.tfvars"},
+			Overridden: []*root.VarValue{{Value: "eu-west-1", Source: root.DefaultSource}},
+		},
+		{
+			Name:     "replicas",
+			VarValue: &root.VarValue{Value: float64(3), Source: "infra/network/prod.tfvars"},
+			Overridden: []*root.VarValue{
+				{Value: float64(1), Source: "infra/network/terraform.tfvars"},
+				{Value: float64(2), Source: "infra/network/network.tfvars.json"},
+			},
+		},
+		{
+			Name:       "tags",
+			VarValue:   &root.VarValue{Value: map[string]interface{}{"team": "network"}, Source: "infra/network/network.tfvars.json"},
+			Overridden: []*root.VarValue{{Value: map[string]interface{}{}, Source: root.DefaultSource}},
+		},
+	}, reports)
+
+	// Workspace var files are only loaded in their workspace.
+	reports, err = root.NewVarReports(rootModule, metadataFile, "")
+	require.NoError(t, err)
+	assert.Equal(t, &root.VarValue{Value: float64(2), Source: "infra/network/network.tfvars.json"}, reports[3].VarValue)
+}
@@ -17,8 +17,10 @@ type CommandVirtualEnv struct {
 	Arch              string `long:"arch"`
 	RootModule        string `long:"root_module"`
 	VirtualEnvBaseDir string `long:"virtual_env_base_dir" default:"./plz-out/terraform/venvs"`
+	Workspace         string `long:"workspace" env:"PLEASE_TERRAFORM_WORKSPACE" description:"The Terraform workspace whose var files are loaded. Select it with 'terraform workspace select -or-create' after init."`
 
 	PleaseOpts *please.Opts
+	Opts       *Opts
 }
 
 // Execute executes the virtualenv subcommand.
@@ -28,23 +30,59 @@ func (c *CommandVirtualEnv) Execute(args []string) error {
 		c.VirtualEnvBaseDir = filepath.Join(repoRoot, c.VirtualEnvBaseDir)
 	}
 
-	// Use absolute path to Terraform binary as we're using this at
-	// `plz run ...` time where the current working directory won't be in the
-	// root of the repository.
+	c.TerraformBinary = absTerraformBinary(repoRoot, c.PleaseOpts.PlzOutDir, c.TerraformBinary)
+
+	// Add the `terraform` binary to the sourcing user's path.
+	fmt.Printf(`PATH="%s:$PATH"`+"\n", filepath.Dir(c.TerraformBinary))
+	fmt.Println(`export PATH`)
+
+	virtualEnvDir, err := NewVirtualEnv(repoRoot, c.PleaseOpts.PlzOutDir, c.VirtualEnvBaseDir, c.RootModule)
+	if err != nil {
+		return err
+	}
+
+	if c.Workspace != "" {
+		if err := UseWorkspace(c.RootModule, c.Opts.MetadataFile, virtualEnvDir, c.Workspace); err != nil {
+			return err
+		}
+		log.Info().Str("workspace", c.Workspace).Msgf("run 'terraform workspace select -or-create %s' after 'terraform init'", c.Workspace)
+	}
+	if err := DecryptVarFiles(repoRoot, c.PleaseOpts.PlzOutDir, virtualEnvDir); err != nil {
+		return err
+	}
+
+	// set REPO_ROOT
+	fmt.Printf(`REPO_ROOT=%s`+"\n", repoRoot)
+	fmt.Println(`export REPO_ROOT`)
+
+	// change user's working directory.
+	log.Info().Str("path", virtualEnvDir).Msg("working directory")
+	fmt.Printf(`cd "%s"`+"\n", virtualEnvDir)
+
+	return nil
+}
+
+// absTerraformBinary returns the absolute path of the given Terraform binary if
+// it is in plz-out. Absolute paths are needed as Terraform is run at
+// `plz run ...` time where the current working directory won't be in the
+// root of the repository.
+func absTerraformBinary(repoRoot string, plzOutDir string, terraformBinary string) string {
 	onlyStartsWithPlzOutRegex := regexp.MustCompile(
-		fmt.Sprintf(`^%c?%s`, filepath.Separator, c.PleaseOpts.PlzOutDir),
+		fmt.Sprintf(`^%c?%s`, filepath.Separator, plzOutDir),
 	)
-	if onlyStartsWithPlzOutRegex.MatchString(c.TerraformBinary) {
-		c.TerraformBinary = filepath.Join(repoRoot, c.TerraformBinary)
+	if onlyStartsWithPlzOutRegex.MatchString(terraformBinary) {
+		terraformBinary = filepath.Join(repoRoot, terraformBinary)
 		log.Debug().
-			Str("path", c.TerraformBinary).
+			Str("path", terraformBinary).
 			Msg("updated terraform_binary to absolute path")
 	}
 
-	// Add the `terraform` binary to the sourcing user's path.
-	fmt.Printf(`PATH="%s:$PATH"`+"\n", filepath.Dir(c.TerraformBinary))
-	fmt.Println(`export PATH`)
+	return terraformBinary
+}
 
+// NewVirtualEnv creates or updates the virtual env directory of the given
+// built Terraform root under the given base directory and returns it.
+func NewVirtualEnv(repoRoot string, plzOutDir string, baseDir string, rootModule string) (string, error) {
 	// We cannot run Terraform commands in the `plz-out/gen/<rule>` directory
 	// as Terraform creates symlinks which Please warns us will be removed.
 	// We also cannot use a `plz-out/terraform` directory as Terraform plans
@@ -54,36 +92,32 @@ func (c *CommandVirtualEnv) Execute(args []string) error {
 	// Instead, we create a 'virtualenv' directory outside of the repository and
 	// copy the generated terraform root. We then replace modules in the
 	// Terraform root with their absolute paths under `plz-out/gen`.
-	virtualEnvDir := filepath.Join(
-		c.VirtualEnvBaseDir,
-		c.RootModule,
-	)
-
-	if err := os.MkdirAll(virtualEnvDir, 0750); err != nil {
-		return fmt.Errorf("could not create virtual env dir '%s': %w", virtualEnvDir, err)
-	}
+	virtualEnvDir := filepath.Join(baseDir, rootModule)
 
-	if err := please.Sync(c.RootModule, virtualEnvDir, []string{
-		`\.terraform.*`,
-		`.*\.tfstate`,
-	}); err != nil {
-		return err
+	if err := SyncVirtualEnv(rootModule, virtualEnvDir); err != nil {
+		return "", err
 	}
 
 	// add symbolic link to plz-out
-	old := filepath.Join(repoRoot, c.PleaseOpts.PlzOutDir)
-	new := filepath.Join(virtualEnvDir, c.PleaseOpts.PlzOutDir)
+	old := filepath.Join(repoRoot, plzOutDir)
+	new := filepath.Join(virtualEnvDir, plzOutDir)
 	if err := os.Symlink(old, new); err != nil {
-		return fmt.Errorf("could not create symlink from '%s' to '%s': %w", old, new, err)
+		return "", fmt.Errorf("could not create symlink from '%s' to '%s': %w", old, new, err)
 	}
 
-	// set REPO_ROOT
-	fmt.Printf(`REPO_ROOT=%s`+"\n", repoRoot)
-	fmt.Println(`export REPO_ROOT`)
+	return virtualEnvDir, nil
+}
 
-	// change user's working directory.
-	log.Info().Str("path", virtualEnvDir).Msg("working directory")
-	fmt.Printf(`cd "%s"`+"\n", virtualEnvDir)
+// SyncVirtualEnv copies the given built Terraform root or module into the
+// given virtual env directory, keeping Terraform's working files and state
+// from previous runs.
+func SyncVirtualEnv(moduleDir string, virtualEnvDir string) error {
+	if err := os.MkdirAll(virtualEnvDir, 0750); err != nil {
+		return fmt.Errorf("could not create virtual env dir '%s': %w", virtualEnvDir, err)
+	}
 
-	return nil
+	return please.Sync(moduleDir, virtualEnvDir, []string{
+		`\.terraform.*`,
+		`.*\.tfstate`,
+	})
 }
@@ -0,0 +1,131 @@
+package root
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/VJftw/please-terraform/pkg/please"
+)
+
+// WorkspacesDir returns the directory, relative to a built root, which holds
+// the var files of each workspace given the root's metadata file.
+func WorkspacesDir(metadataFile string) string {
+	return filepath.Join(filepath.Dir(metadataFile), "workspaces")
+}
+
+// WorkspaceTFVarsName returns a tfvars file name that will be automatically
+// loaded by Terraform, after those named by AutoTFVarsName, for the given
+// index and workspace var file.
+func WorkspaceTFVarsName(i int, varFile string) (string, error) {
+	name, err := AutoTFVarsName(i, varFile)
+	if err != nil {
+		return "", err
+	}
+
+	return "workspace-" + name, nil
+}
+
+// ParseWorkspaceVarFiles returns the var files of each workspace from the given
+// `<workspace>=<var file>` pairs.
+func ParseWorkspaceVarFiles(pairs []string) (map[string][]string, error) {
+	varFiles := map[string][]string{}
+	for _, pair := range pairs {
+		workspace, varFile, found := strings.Cut(pair, "=")
+		if !found || workspace == "" || varFile == "" {
+			return nil, fmt.Errorf("workspace var file '%s' is not in the form '<workspace>=<var file>'", pair)
+		}
+		varFiles[workspace] = append(varFiles[workspace], varFile)
+	}
+
+	return varFiles, nil
+}
+
+// copyWorkspaceVarFiles copies the given var files of each workspace into the
+// workspaces directory of the given built root.
+func copyWorkspaceVarFiles(out string, workspacesDir string, varFiles map[string][]string) error {
+	for workspace, files := range varFiles {
+		for i, varFile := range files {
+			newName, err := WorkspaceTFVarsName(i, varFile)
+			if err != nil {
+				return err
+			}
+			if err := checkEncrypted(varFile); err != nil {
+				return err
+			}
+			dest := filepath.Join(out, workspacesDir, workspace, newName)
+			if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+				return err
+			}
+			if err := please.CopyFile(varFile, dest); err != nil {
+				return fmt.Errorf("could not copy var file '%s' of workspace '%s': %w", varFile, workspace, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// UseWorkspace copies the var files of the given workspace from the given
+// built root into its virtual env, where Terraform auto-loads them. It fails
+// if the root declares its workspaces and the given one is not among them.
+func UseWorkspace(rootModule string, metadataFile string, virtualEnvDir string, workspace string) error {
+	m, err := LoadMetadata(filepath.Join(rootModule, metadataFile))
+	if err != nil {
+		return err
+	}
+
+	if len(m.Workspaces) > 0 && !contains(m.Workspaces, workspace) {
+		return fmt.Errorf("workspace '%s' is not one of the root's workspaces: %s", workspace, strings.Join(m.Workspaces, ", "))
+	}
+
+	workspaceDir := filepath.Join(rootModule, WorkspacesDir(metadataFile), workspace)
+	entries, err := os.ReadDir(workspaceDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read directory '%s': %w", workspaceDir, err)
+	}
+
+	for _, entry := range entries {
+		if err := please.CopyFile(filepath.Join(workspaceDir, entry.Name()), filepath.Join(virtualEnvDir, entry.Name())); err != nil {
+			return err
+		}
+		log.Debug().Str("workspace", workspace).Str("tfvars", entry.Name()).Msg("configured workspace tfvars")
+	}
+
+	return nil
+}
+
+// workspaces returns the sorted, unique workspaces from the given declared
+// workspaces and those with var files.
+func workspaces(declared []string, varFiles map[string][]string) []string {
+	set := map[string]struct{}{}
+	for _, workspace := range declared {
+		set[workspace] = struct{}{}
+	}
+	for workspace := range varFiles {
+		set[workspace] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(set))
+	for workspace := range set {
+		sorted = append(sorted, workspace)
+	}
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,34 @@
+package root_test
+
+import (
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/root"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceTFVarsName(t *testing.T) {
+	name, err := root.WorkspaceTFVarsName(1, "envs/dev.tfvars.json")
+	require.NoError(t, err)
+	assert.Equal(t, "workspace-1-dev.auto.tfvars.json", name)
+
+	_, err = root.WorkspaceTFVarsName(0, "dev")
+	assert.Error(t, err)
+}
+
+func TestParseWorkspaceVarFiles(t *testing.T) {
+	varFiles, err := root.ParseWorkspaceVarFiles([]string{
+		"dev=envs/dev.tfvars",
+		"dev=envs/dev-secrets.tfvars",
+		"prod=envs/prod.tfvars",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"dev":  {"envs/dev.tfvars", "envs/dev-secrets.tfvars"},
+		"prod": {"envs/prod.tfvars"},
+	}, varFiles)
+
+	_, err = root.ParseWorkspaceVarFiles([]string{"envs/dev.tfvars"})
+	assert.ErrorContains(t, err, "is not in the form '<workspace>=<var file>'")
+}
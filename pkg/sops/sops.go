@@ -0,0 +1,440 @@
+// Package sops decrypts files encrypted by SOPS (https://github.com/getsops/sops)
+// with age keys, without depending on the `sops` binary.
+package sops
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+
+	"github.com/VJftw/please-terraform/internal/logging"
+)
+
+var log = &logging.Logger
+
+// Format represents the format which SOPS stored a file in.
+type Format string
+
+const (
+	// FormatJSON is the format of JSON files, whose values are encrypted
+	// individually.
+	FormatJSON Format = "json"
+	// FormatBinary is the format of files which SOPS does not understand,
+	// e.g. `.tfvars`, which are encrypted as a whole into a JSON document.
+	FormatBinary Format = "binary"
+)
+
+// FormatOf returns the Format which SOPS stores the file with the given name
+// in, as determined by SOPS from the file's extension.
+func FormatOf(name string) Format {
+	if strings.HasSuffix(name, ".json") {
+		return FormatJSON
+	}
+
+	return FormatBinary
+}
+
+// Metadata represents the `sops` metadata of an encrypted file.
+type Metadata struct {
+	Age               []*AgeKey     `json:"age"`
+	KeyGroups         []interface{} `json:"key_groups"`
+	LastModified      string        `json:"lastmodified"`
+	MAC               string        `json:"mac"`
+	UnencryptedSuffix string        `json:"unencrypted_suffix"`
+	EncryptedSuffix   string        `json:"encrypted_suffix"`
+	UnencryptedRegex  string        `json:"unencrypted_regex"`
+	EncryptedRegex    string        `json:"encrypted_regex"`
+	MACOnlyEncrypted  bool          `json:"mac_only_encrypted"`
+	Version           string        `json:"version"`
+}
+
+// AgeKey represents the data key of an encrypted file encrypted to an age
+// recipient.
+type AgeKey struct {
+	Recipient string `json:"recipient"`
+	Enc       string `json:"enc"`
+}
+
+// IsEncrypted returns whether the given file contents are a file encrypted by
+// SOPS.
+func IsEncrypted(data []byte) bool {
+	doc := struct {
+		SOPS *Metadata `json:"sops"`
+	}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+
+	return doc.SOPS != nil && doc.SOPS.MAC != ""
+}
+
+// Identities returns the age identities which SOPS would decrypt files with,
+// from the `SOPS_AGE_KEY` and `SOPS_AGE_KEY_FILE` environment variables and
+// the default `sops/age/keys.txt` in the user's config directory.
+func Identities() ([]age.Identity, error) {
+	identities := []age.Identity{}
+
+	if key := os.Getenv("SOPS_AGE_KEY"); key != "" {
+		keyIdentities, err := age.ParseIdentities(strings.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse SOPS_AGE_KEY: %w", err)
+		}
+		identities = append(identities, keyIdentities...)
+	}
+
+	keyFiles := []string{}
+	if keyFile := os.Getenv("SOPS_AGE_KEY_FILE"); keyFile != "" {
+		keyFiles = append(keyFiles, keyFile)
+	}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		keyFiles = append(keyFiles, filepath.Join(configDir, "sops", "age", "keys.txt"))
+	}
+	for _, keyFile := range keyFiles {
+		f, err := os.Open(keyFile)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not open age key file '%s': %w", keyFile, err)
+		}
+		fileIdentities, err := age.ParseIdentities(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not parse age key file '%s': %w", keyFile, err)
+		}
+		identities = append(identities, fileIdentities...)
+	}
+
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no age keys found, set SOPS_AGE_KEY_FILE or SOPS_AGE_KEY")
+	}
+
+	return identities, nil
+}
+
+// Decrypt returns the plaintext of the given file encrypted by SOPS in the
+// given format, using the given age identities to decrypt its data key. The
+// file's message authentication code is verified.
+func Decrypt(data []byte, format Format, identities []age.Identity) ([]byte, error) {
+	tree, err := parseJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse encrypted file: %w", err)
+	}
+	doc, ok := tree.(branch)
+	if !ok {
+		return nil, fmt.Errorf("encrypted file is not a JSON object")
+	}
+
+	metadataValue, ok := doc.get("sops")
+	if !ok {
+		return nil, fmt.Errorf("encrypted file has no sops metadata")
+	}
+	doc = doc.without("sops")
+	metadataBytes, err := json.Marshal(metadataValue)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal sops metadata: %w", err)
+	}
+	metadata := &Metadata{}
+	if err := json.Unmarshal(metadataBytes, metadata); err != nil {
+		return nil, fmt.Errorf("could not parse sops metadata: %w", err)
+	}
+
+	dataKey, err := metadata.dataKey(identities)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &decrypter{metadata: metadata, key: dataKey, hash: sha512.New()}
+	if err := d.compileRules(); err != nil {
+		return nil, err
+	}
+	plain, err := d.walk(doc, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.verifyMAC(); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatBinary:
+		value, ok := plain.(branch).get("data")
+		if !ok {
+			return nil, fmt.Errorf("encrypted binary file has no 'data'")
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("encrypted binary file's 'data' is not a string")
+		}
+		return []byte(s), nil
+	default:
+		out, err := json.Marshal(plain)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal decrypted file: %w", err)
+		}
+		indented := &bytes.Buffer{}
+		if err := json.Indent(indented, out, "", "  "); err != nil {
+			return nil, fmt.Errorf("could not indent decrypted file: %w", err)
+		}
+		indented.WriteString("\n")
+		return indented.Bytes(), nil
+	}
+}
+
+// dataKey returns the file's data key, decrypted with one of the given
+// identities.
+func (m *Metadata) dataKey(identities []age.Identity) ([]byte, error) {
+	if len(m.KeyGroups) > 0 {
+		return nil, fmt.Errorf("files encrypted with key groups are not supported")
+	}
+	if len(m.Age) == 0 {
+		return nil, fmt.Errorf("file is not encrypted with any age recipients")
+	}
+
+	for _, key := range m.Age {
+		r, err := age.Decrypt(armor.NewReader(strings.NewReader(key.Enc)), identities...)
+		if err != nil {
+			log.Debug().Err(err).Str("recipient", key.Recipient).Msg("could not decrypt data key")
+			continue
+		}
+		dataKey, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("could not read data key of '%s': %w", key.Recipient, err)
+		}
+		return dataKey, nil
+	}
+
+	recipients := make([]string, 0, len(m.Age))
+	for _, key := range m.Age {
+		recipients = append(recipients, key.Recipient)
+	}
+	return nil, fmt.Errorf("none of the age keys can decrypt the file, which is encrypted to: %s", strings.Join(recipients, ", "))
+}
+
+// decrypter decrypts the values of a file, hashing them in the order in which
+// SOPS computes the file's message authentication code.
+type decrypter struct {
+	metadata *Metadata
+	key      []byte
+	hash     hash.Hash
+
+	unencryptedRegex *regexp.Regexp
+	encryptedRegex   *regexp.Regexp
+}
+
+func (d *decrypter) compileRules() error {
+	var err error
+	if d.metadata.UnencryptedRegex != "" {
+		if d.unencryptedRegex, err = regexp.Compile(d.metadata.UnencryptedRegex); err != nil {
+			return fmt.Errorf("invalid unencrypted_regex: %w", err)
+		}
+	}
+	if d.metadata.EncryptedRegex != "" {
+		if d.encryptedRegex, err = regexp.Compile(d.metadata.EncryptedRegex); err != nil {
+			return fmt.Errorf("invalid encrypted_regex: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// encrypted returns whether the value at the given path is encrypted,
+// following SOPS' rules.
+func (d *decrypter) encrypted(path []string) bool {
+	m := d.metadata
+	switch {
+	case m.UnencryptedSuffix != "":
+		for _, key := range path {
+			if strings.HasSuffix(key, m.UnencryptedSuffix) {
+				return false
+			}
+		}
+	case m.EncryptedSuffix != "":
+		for _, key := range path {
+			if strings.HasSuffix(key, m.EncryptedSuffix) {
+				return true
+			}
+		}
+		return false
+	case d.unencryptedRegex != nil:
+		for _, key := range path {
+			if d.unencryptedRegex.MatchString(key) {
+				return false
+			}
+		}
+	case d.encryptedRegex != nil:
+		for _, key := range path {
+			if d.encryptedRegex.MatchString(key) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+func (d *decrypter) walk(value interface{}, path []string) (interface{}, error) {
+	switch v := value.(type) {
+	case branch:
+		out := branch{}
+		for _, item := range v {
+			plain, err := d.walk(item.value, append(append([]string{}, path...), item.key))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, &branchItem{key: item.key, value: plain})
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, element := range v {
+			plain, err := d.walk(element, path)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, plain)
+		}
+		return out, nil
+	}
+
+	encrypted := d.encrypted(path)
+	plain := value
+	if encrypted {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("value of '%s' is not encrypted", strings.Join(path, "."))
+		}
+		var err error
+		plain, err = decryptValue(s, d.key, strings.Join(path, ":")+":")
+		if err != nil {
+			return nil, fmt.Errorf("could not decrypt value of '%s': %w", strings.Join(path, "."), err)
+		}
+	}
+
+	if !d.metadata.MACOnlyEncrypted || encrypted {
+		valueBytes, err := toBytes(plain)
+		if err != nil {
+			return nil, fmt.Errorf("could not hash value of '%s': %w", strings.Join(path, "."), err)
+		}
+		_, _ = d.hash.Write(valueBytes)
+	}
+
+	return plain, nil
+}
+
+// verifyMAC checks the hash of the decrypted values against the file's
+// message authentication code, which is encrypted with the time the file was
+// last modified as its additional data.
+func (d *decrypter) verifyMAC() error {
+	lastModified, err := time.Parse(time.RFC3339, d.metadata.LastModified)
+	if err != nil {
+		return fmt.Errorf("invalid lastmodified '%s': %w", d.metadata.LastModified, err)
+	}
+
+	mac, err := decryptValue(d.metadata.MAC, d.key, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("could not decrypt message authentication code: %w", err)
+	}
+
+	if mac != fmt.Sprintf("%X", d.hash.Sum(nil)) {
+		return fmt.Errorf("message authentication code does not match, the file has been modified")
+	}
+
+	return nil
+}
+
+var encryptedValueRegex = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]`)
+
+// decryptValue decrypts a value encrypted by SOPS, returning it as its
+// original type.
+func decryptValue(value string, key []byte, additionalData string) (interface{}, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	matches := encryptedValueRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return nil, fmt.Errorf("value is not in the form 'ENC[AES256_GCM,data:...,iv:...,tag:...,type:...]'")
+	}
+	decoded := make([][]byte, 3)
+	for i, match := range matches[1:4] {
+		b, err := base64.StdEncoding.DecodeString(match)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode value: %w", err)
+		}
+		decoded[i] = b
+	}
+	data, iv, tag := decoded[0], decoded[1], decoded[2]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt value: %w", err)
+	}
+
+	switch valueType := matches[4]; valueType {
+	case "str", "comment":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	case "bytes":
+		return plaintext, nil
+	default:
+		return nil, fmt.Errorf("unknown value type '%s'", valueType)
+	}
+}
+
+// toBytes returns the bytes which SOPS hashes for the given value.
+func toBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatFloat(f, 'f', -1, 64)), nil
+	case bool:
+		// SOPS hashes booleans as "True" or "False".
+		if v {
+			return []byte("True"), nil
+		}
+		return []byte("False"), nil
+	}
+
+	return nil, fmt.Errorf("cannot hash value of type %T", value)
+}
@@ -0,0 +1,203 @@
+package sops_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/VJftw/please-terraform/pkg/sops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// item is a key and value of a file to encrypt, in order.
+type item struct {
+	key   string
+	value interface{}
+}
+
+// encrypt returns the given items encrypted as SOPS does to the given
+// recipient, leaving values whose keys end in `_unencrypted` as they are.
+func encrypt(t *testing.T, recipient *age.X25519Recipient, items []item) []byte {
+	dataKey := make([]byte, 32)
+	_, err := rand.Read(dataKey)
+	require.NoError(t, err)
+
+	encryptValue := func(plaintext string, valueType string, additionalData string) string {
+		block, err := aes.NewCipher(dataKey)
+		require.NoError(t, err)
+		iv := make([]byte, 32)
+		_, err = rand.Read(iv)
+		require.NoError(t, err)
+		gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+		require.NoError(t, err)
+		out := gcm.Seal(nil, iv, []byte(plaintext), []byte(additionalData))
+		return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+			base64.StdEncoding.EncodeToString(out[:len(out)-aes.BlockSize]),
+			base64.StdEncoding.EncodeToString(iv),
+			base64.StdEncoding.EncodeToString(out[len(out)-aes.BlockSize:]),
+			valueType,
+		)
+	}
+
+	hash := sha512.New()
+	doc := &bytes.Buffer{}
+	doc.WriteString("{")
+	for _, it := range items {
+		var plaintext, valueType string
+		switch v := it.value.(type) {
+		case string:
+			plaintext, valueType = v, "str"
+		case int:
+			plaintext, valueType = strconv.Itoa(v), "int"
+		case bool:
+			plaintext, valueType = strings.Title(strconv.FormatBool(v)), "bool"
+		}
+		hash.Write([]byte(plaintext))
+
+		value := it.value
+		if !strings.HasSuffix(it.key, "_unencrypted") {
+			value = encryptValue(plaintext, valueType, it.key+":")
+		}
+		fmt.Fprintf(doc, "%q: %s,", it.key, mustJSON(t, value))
+	}
+
+	encryptedKey := &bytes.Buffer{}
+	armorWriter := armor.NewWriter(encryptedKey)
+	ageWriter, err := age.Encrypt(armorWriter, recipient)
+	require.NoError(t, err)
+	_, err = ageWriter.Write(dataKey)
+	require.NoError(t, err)
+	require.NoError(t, ageWriter.Close())
+	require.NoError(t, armorWriter.Close())
+
+	lastModified := time.Now().UTC().Format(time.RFC3339)
+	fmt.Fprintf(doc, `"sops": {"kms": null, "age": [{"recipient": %q, "enc": %s}], "lastmodified": %q, "mac": %q, "unencrypted_suffix": "_unencrypted", "version": "3.8.1"}}`,
+		recipient.String(),
+		mustJSON(t, encryptedKey.String()),
+		lastModified,
+		encryptValue(fmt.Sprintf("%X", hash.Sum(nil)), "str", lastModified),
+	)
+
+	return doc.Bytes()
+}
+
+func mustJSON(t *testing.T, value interface{}) []byte {
+	b, err := json.Marshal(value)
+	require.NoError(t, err)
+	return b
+}
+
+func TestDecrypt(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	otherIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	t.Run("json", func(t *testing.T) {
+		encrypted := encrypt(t, identity.Recipient(), []item{
+			{"password", "hunter2"},
+			{"replicas", 3},
+			{"enabled", true},
+			{"region_unencrypted", "eu-west-1"},
+		})
+		assert.True(t, sops.IsEncrypted(encrypted))
+
+		plaintext, err := sops.Decrypt(encrypted, sops.FormatJSON, []age.Identity{otherIdentity, identity})
+		require.NoError(t, err)
+		assert.Equal(t, `{
+  "password": "hunter2",
+  "replicas": 3,
+  "enabled": true,
+  "region_unencrypted": "eu-west-1"
+}
+`, string(plaintext))
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		encrypted := encrypt(t, identity.Recipient(), []item{{"data", "password = \"hunter2\"\n"}})
+
+		plaintext, err := sops.Decrypt(encrypted, sops.FormatBinary, []age.Identity{identity})
+		require.NoError(t, err)
+		assert.Equal(t, "password = \"hunter2\"\n", string(plaintext))
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		encrypted := encrypt(t, identity.Recipient(), []item{{"data", "password = \"hunter2\"\n"}})
+
+		_, err := sops.Decrypt(encrypted, sops.FormatBinary, []age.Identity{otherIdentity})
+		assert.ErrorContains(t, err, "none of the age keys can decrypt the file, which is encrypted to: "+identity.Recipient().String())
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		encrypted := encrypt(t, identity.Recipient(), []item{
+			{"password", "hunter2"},
+			{"region_unencrypted", "eu-west-1"},
+		})
+		modified := bytes.Replace(encrypted, []byte("eu-west-1"), []byte("eu-west-2"), 1)
+
+		_, err := sops.Decrypt(modified, sops.FormatJSON, []age.Identity{identity})
+		assert.ErrorContains(t, err, "message authentication code does not match")
+	})
+
+	t.Run("plaintext", func(t *testing.T) {
+		assert.False(t, sops.IsEncrypted([]byte(`{"password": "hunter2"}`)))
+		assert.False(t, sops.IsEncrypted([]byte(`password = "hunter2"`)))
+	})
+}
+
+// The files in testdata were encrypted by the stores and cipher of SOPS 3.8.1
+// to the throwaway age key in testdata/keys.txt.
+func TestDecryptSOPSFiles(t *testing.T) {
+	keys, err := os.Open("testdata/keys.txt")
+	require.NoError(t, err)
+	defer keys.Close()
+	identities, err := age.ParseIdentities(keys)
+	require.NoError(t, err)
+
+	t.Run("tfvars", func(t *testing.T) {
+		encrypted, err := os.ReadFile("testdata/terraform.sops.tfvars")
+		require.NoError(t, err)
+		assert.True(t, sops.IsEncrypted(encrypted))
+
+		plaintext, err := sops.Decrypt(encrypted, sops.FormatOf("terraform.sops.tfvars"), identities)
+		require.NoError(t, err)
+		assert.Equal(t, `password = "hunter2"
+replicas = 3
+`, string(plaintext))
+	})
+
+	t.Run("tfvars.json", func(t *testing.T) {
+		encrypted, err := os.ReadFile("testdata/terraform.sops.tfvars.json")
+		require.NoError(t, err)
+		assert.True(t, sops.IsEncrypted(encrypted))
+
+		plaintext, err := sops.Decrypt(encrypted, sops.FormatOf("terraform.sops.tfvars.json"), identities)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+  "password": "hunter2",
+  "replicas": 3,
+  "ratio": 0.5,
+  "enabled": true,
+  "tags": {"team": "platform"},
+  "zones": ["a", "b"],
+  "region_unencrypted": "eu-west-1"
+}`, string(plaintext))
+
+		modified := bytes.Replace(encrypted, []byte(`"eu-west-1"`), []byte(`"eu-west-2"`), 1)
+		_, err = sops.Decrypt(modified, sops.FormatOf("terraform.sops.tfvars.json"), identities)
+		assert.ErrorContains(t, err, "message authentication code does not match")
+	})
+}
@@ -0,0 +1,125 @@
+package sops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// branch represents a JSON object whose keys keep their order, as SOPS hashes
+// values in the order in which they appear in a file.
+type branch []*branchItem
+
+type branchItem struct {
+	key   string
+	value interface{}
+}
+
+func (b branch) get(key string) (interface{}, bool) {
+	for _, item := range b {
+		if item.key == key {
+			return item.value, true
+		}
+	}
+
+	return nil, false
+}
+
+func (b branch) without(key string) branch {
+	out := branch{}
+	for _, item := range b {
+		if item.key != key {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
+
+// MarshalJSON marshals the branch as a JSON object in its order.
+func (b branch) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("{")
+	for i, item := range b {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, err := json.Marshal(item.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(item.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(value)
+	}
+	buf.WriteString("}")
+
+	return buf.Bytes(), nil
+}
+
+// parseJSON parses the given JSON document, keeping the order of the keys of
+// its objects.
+func parseJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	value, err := parseJSONValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON document")
+	}
+
+	return value, nil
+}
+
+func parseJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		b := branch{}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := keyToken.(string)
+			if !ok {
+				return nil, fmt.Errorf("unexpected object key %v", keyToken)
+			}
+			value, err := parseJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, &branchItem{key: key, value: value})
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return b, nil
+	case json.Delim('['):
+		values := []interface{}{}
+		for decoder.More() {
+			value, err := parseJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+
+	return token, nil
+}
@@ -0,0 +1,163 @@
+package tfconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// Backend represents a Terraform state backend and its configuration.
+type Backend struct {
+	Type   string
+	Config map[string]interface{}
+	// Unresolved are the attributes of the configuration whose values are
+	// not static, e.g. as they refer to variables, and so are not in Config.
+	Unresolved []string `json:",omitempty"`
+}
+
+// backendStateKeys are the attributes which locate the state of the backends
+// which they are known for.
+var backendStateKeys = map[string][]string{
+	"azurerm":    {"container_name", "key", "storage_account_name"},
+	"consul":     {"path"},
+	"gcs":        {"bucket"},
+	"http":       {"address"},
+	"kubernetes": {"secret_suffix"},
+	"local":      {"path"},
+	"s3":         {"bucket", "key"},
+}
+
+// backendOptionalStateKeys are the attributes which also locate the state of
+// the backends which they are known for, but which have defaults.
+var backendOptionalStateKeys = map[string][]string{
+	"gcs":   {"prefix"},
+	"local": {"workspace_dir"},
+	"s3":    {"workspace_key_prefix"},
+}
+
+// RemoteState represents a `terraform_remote_state` data source.
+type RemoteState struct {
+	Name    string
+	Backend *Backend
+}
+
+var remoteStateSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "backend", Required: true},
+		{Name: "config"},
+	},
+}
+
+func loadBackend(block *hcl.Block) (*Backend, hcl.Diagnostics) {
+	b := &Backend{
+		Type:   block.Labels[0],
+		Config: map[string]interface{}{},
+	}
+
+	attrs, diags := block.Body.JustAttributes()
+	for name, attr := range attrs {
+		if val, ok := staticValue(attr.Expr); ok {
+			b.Config[name] = val
+			continue
+		}
+		b.Unresolved = append(b.Unresolved, name)
+	}
+	sort.Strings(b.Unresolved)
+
+	return b, diags
+}
+
+func loadRemoteState(block *hcl.Block) (*RemoteState, hcl.Diagnostics) {
+	rs := &RemoteState{
+		Name:    block.Labels[1],
+		Backend: &Backend{Config: map[string]interface{}{}},
+	}
+
+	content, _, diags := block.Body.PartialContent(remoteStateSchema)
+	if attr, ok := content.Attributes["backend"]; ok {
+		if val, ok := staticValue(attr.Expr); ok {
+			rs.Backend.Type = fmt.Sprint(val)
+		}
+	}
+
+	if attr, ok := content.Attributes["config"]; ok {
+		// Evaluate each item separately so that values which reference
+		// variables or locals do not prevent the others from being known.
+		items, itemDiags := hcl.ExprMap(attr.Expr)
+		if itemDiags.HasErrors() {
+			return rs, diags
+		}
+		for _, item := range items {
+			key, ok := staticValue(item.Key)
+			if !ok {
+				continue
+			}
+			if val, ok := staticValue(item.Value); ok {
+				rs.Backend.Config[fmt.Sprint(key)] = val
+			}
+		}
+	}
+
+	return rs, diags
+}
+
+// MissingConfig returns the attributes which locate the backend's state but
+// are not configured, e.g. as they are given to `terraform init` with
+// `-backend-config`. Only the attributes of common backends are known.
+func (b *Backend) MissingConfig() []string {
+	missing := []string{}
+	for _, key := range backendStateKeys[b.Type] {
+		if _, ok := b.Config[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	return missing
+}
+
+// Matches returns whether the given backend, as read by a
+// `terraform_remote_state` data source, refers to the state stored by this
+// backend. The backend types must be the same, the attributes which locate
+// the state of known backends must be configured by both and be the same,
+// and every other configuration key that they have in common must have the
+// same value. Backends whose state attributes are not known match if they
+// have at least one configuration key in common.
+func (b *Backend) Matches(other *Backend) bool {
+	if b == nil || other == nil || b.Type != other.Type {
+		return false
+	}
+
+	stateKeys, known := backendStateKeys[b.Type]
+	for _, key := range stateKeys {
+		ownVal, ok := b.Config[key]
+		if !ok {
+			return false
+		}
+		if val, ok := other.Config[key]; !ok || !reflect.DeepEqual(ownVal, val) {
+			return false
+		}
+	}
+	for _, key := range backendOptionalStateKeys[b.Type] {
+		ownVal, ownOK := b.Config[key]
+		val, ok := other.Config[key]
+		if ownOK != ok || !reflect.DeepEqual(ownVal, val) {
+			return false
+		}
+	}
+
+	common := 0
+	for key, val := range other.Config {
+		ownVal, ok := b.Config[key]
+		if !ok {
+			continue
+		}
+		if !reflect.DeepEqual(ownVal, val) {
+			return false
+		}
+		common++
+	}
+
+	return known || common > 0
+}
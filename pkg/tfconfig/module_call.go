@@ -0,0 +1,110 @@
+package tfconfig
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// ModuleCall represents a `module` block.
+type ModuleCall struct {
+	Name    string
+	Source  string
+	Version string
+
+	DeclRange hcl.Range
+	// SourceRange is the range of the `source` argument's value.
+	SourceRange hcl.Range
+	// VersionRange is the range of the whole `version` argument, if any.
+	VersionRange *hcl.Range
+}
+
+var moduleCallSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "source"},
+		{Name: "version"},
+	},
+}
+
+func loadModuleCall(block *hcl.Block) (*ModuleCall, hcl.Diagnostics) {
+	mc := &ModuleCall{
+		Name:      block.Labels[0],
+		DeclRange: block.DefRange,
+	}
+
+	content, _, diags := block.Body.PartialContent(moduleCallSchema)
+	if attr, ok := content.Attributes["source"]; ok {
+		if val, ok := staticValue(attr.Expr); ok {
+			mc.Source = fmt.Sprint(val)
+		}
+		mc.SourceRange = attr.Expr.Range()
+	}
+	if attr, ok := content.Attributes["version"]; ok {
+		if val, ok := staticValue(attr.Expr); ok {
+			mc.Version = fmt.Sprint(val)
+		}
+		versionRange := attr.Range
+		mc.VersionRange = &versionRange
+	}
+
+	return mc, diags
+}
+
+// IsLocalSource returns whether the given module source is a path on the
+// local filesystem.
+func IsLocalSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// IsPleaseSource returns whether the given module source is a Please build
+// label.
+func IsPleaseSource(source string) bool {
+	return strings.HasPrefix(source, "//")
+}
+
+// RegistrySource represents a module source address in a Terraform module
+// registry, e.g. `hashicorp/consul/aws`.
+type RegistrySource struct {
+	// Host is the registry's hostname, empty for the public registry.
+	Host      string
+	Namespace string
+	Name      string
+	Provider  string
+	// Subdir is the module's subdirectory within the package, if any.
+	Subdir string
+}
+
+// registrySourceRegex matches `[<host>/]<namespace>/<name>/<provider>[//<subdir>]`.
+var registrySourceRegex = regexp.MustCompile(
+	`^(?:([0-9A-Za-z.-]+\.[A-Za-z]+(?::[0-9]+)?)/)?([0-9A-Za-z_-]+)/([0-9A-Za-z_-]+)/([0-9a-z]+)(?://(.*))?$`,
+)
+
+// ParseRegistrySource returns the RegistrySource for the given module source
+// if it is a registry address.
+func ParseRegistrySource(source string) (*RegistrySource, bool) {
+	matches := registrySourceRegex.FindStringSubmatch(source)
+	if matches == nil {
+		return nil, false
+	}
+
+	return &RegistrySource{
+		Host:      matches[1],
+		Namespace: matches[2],
+		Name:      matches[3],
+		Provider:  matches[4],
+		Subdir:    matches[5],
+	}, true
+}
+
+// Address returns the registry address of the module package without the
+// subdirectory, e.g. `hashicorp/consul/aws`.
+func (s *RegistrySource) Address() string {
+	address := fmt.Sprintf("%s/%s/%s", s.Namespace, s.Name, s.Provider)
+	if s.Host != "" {
+		address = s.Host + "/" + address
+	}
+
+	return address
+}
@@ -0,0 +1,194 @@
+// Package tfconfig parses the parts of Terraform configuration that
+// please_terraform needs to reason about without running Terraform.
+package tfconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Module represents the Terraform configuration found in a single directory.
+type Module struct {
+	Backend      *Backend
+	RemoteStates []*RemoteState
+	ModuleCalls  []*ModuleCall
+	// ProviderConfigs are the names of the providers configured with
+	// `provider` blocks.
+	ProviderConfigs   []string
+	Variables         []*Variable
+	Outputs           []*Output
+	RequiredProviders []*RequiredProvider
+}
+
+var fileSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "terraform"},
+		{Type: "data", LabelNames: []string{"type", "name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "provider", LabelNames: []string{"name"}},
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "output", LabelNames: []string{"name"}},
+	},
+}
+
+var terraformBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "backend", LabelNames: []string{"type"}},
+		{Type: "required_providers"},
+	},
+}
+
+// LoadDir parses the Terraform files in the given directory. Subdirectories
+// are not loaded as Terraform does not load them either. An error is returned
+// alongside the partially loaded Module if any file could not be parsed.
+func LoadDir(dir string) (*Module, error) {
+	files, err := ConfigFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Module{}
+	parser := hclparse.NewParser()
+	var diags hcl.Diagnostics
+	for _, file := range files {
+		f, fileDiags := parseFile(parser, file)
+		diags = append(diags, fileDiags...)
+		if f == nil {
+			continue
+		}
+		diags = append(diags, m.load(f)...)
+	}
+
+	if diags.HasErrors() {
+		return m, diags
+	}
+
+	return m, nil
+}
+
+// ConfigFiles returns the sorted Terraform configuration files in the given
+// directory.
+func ConfigFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read directory '%s': %w", dir, err)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if IsConfigFile(entry.Name()) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// IsConfigFile returns whether the given file name is a Terraform
+// configuration file.
+func IsConfigFile(name string) bool {
+	return strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json")
+}
+
+func parseFile(parser *hclparse.Parser, path string) (*hcl.File, hcl.Diagnostics) {
+	var (
+		file  *hcl.File
+		diags hcl.Diagnostics
+	)
+	if strings.HasSuffix(path, ".json") {
+		file, diags = parser.ParseJSONFile(path)
+	} else {
+		file, diags = parser.ParseHCLFile(path)
+	}
+
+	return file, diags
+}
+
+func (m *Module) load(file *hcl.File) hcl.Diagnostics {
+	content, _, diags := file.Body.PartialContent(fileSchema)
+
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "terraform":
+			tfContent, _, tfDiags := block.Body.PartialContent(terraformBlockSchema)
+			diags = append(diags, tfDiags...)
+			for _, tfBlock := range tfContent.Blocks {
+				switch tfBlock.Type {
+				case "backend":
+					backend, backendDiags := loadBackend(tfBlock)
+					diags = append(diags, backendDiags...)
+					m.Backend = backend
+				case "required_providers":
+					providers, rpDiags := loadRequiredProviders(tfBlock)
+					diags = append(diags, rpDiags...)
+					m.RequiredProviders = append(m.RequiredProviders, providers...)
+				}
+			}
+		case "data":
+			if block.Labels[0] != "terraform_remote_state" {
+				continue
+			}
+			remoteState, rsDiags := loadRemoteState(block)
+			diags = append(diags, rsDiags...)
+			m.RemoteStates = append(m.RemoteStates, remoteState)
+		case "module":
+			moduleCall, mcDiags := loadModuleCall(block)
+			diags = append(diags, mcDiags...)
+			m.ModuleCalls = append(m.ModuleCalls, moduleCall)
+		case "provider":
+			m.ProviderConfigs = append(m.ProviderConfigs, block.Labels[0])
+		case "variable":
+			variable, varDiags := loadVariable(block, file.Bytes)
+			diags = append(diags, varDiags...)
+			m.Variables = append(m.Variables, variable)
+		case "output":
+			output, outputDiags := loadOutput(block)
+			diags = append(diags, outputDiags...)
+			m.Outputs = append(m.Outputs, output)
+		}
+	}
+
+	return diags
+}
+
+// staticValue returns the Go representation of the given expression if it
+// can be evaluated without any variables or functions.
+func staticValue(expr hcl.Expression) (interface{}, bool) {
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, false
+	}
+
+	return ctyToGo(val)
+}
+
+func ctyToGo(val cty.Value) (interface{}, bool) {
+	if val.IsNull() || !val.IsWhollyKnown() {
+		return nil, false
+	}
+
+	jsonBytes, err := ctyjson.Marshal(val, val.Type())
+	if err != nil {
+		return nil, false
+	}
+
+	var out interface{}
+	if err := json.Unmarshal(jsonBytes, &out); err != nil {
+		return nil, false
+	}
+
+	return out, true
+}
@@ -0,0 +1,423 @@
+package tfconfig_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/tfconfig"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"backend.tf": `
+terraform {
+  backend "s3" {
+    bucket  = "my-terraform-state"
+    key     = "infra/app/app.tfstate"
+    encrypt = true
+  }
+}
+`,
+		"data.tf": `
+data "terraform_remote_state" "network" {
+  backend = "s3"
+  config = {
+    bucket = "my-terraform-state"
+    key    = "infra/network/network.tfstate"
+    region = var.region
+  }
+}
+
+data "aws_caller_identity" "current" {}
+`,
+		"ignored.txt": `not terraform`,
+	})
+
+	m, err := tfconfig.LoadDir(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, &tfconfig.Backend{
+		Type: "s3",
+		Config: map[string]interface{}{
+			"bucket":  "my-terraform-state",
+			"key":     "infra/app/app.tfstate",
+			"encrypt": true,
+		},
+	}, m.Backend)
+
+	assert.Equal(t, []*tfconfig.RemoteState{
+		{
+			Name: "network",
+			Backend: &tfconfig.Backend{
+				Type: "s3",
+				Config: map[string]interface{}{
+					"bucket": "my-terraform-state",
+					"key":    "infra/network/network.tfstate",
+				},
+			},
+		},
+	}, m.RemoteStates)
+}
+
+func TestBackendMatches(t *testing.T) {
+	backend := &tfconfig.Backend{
+		Type: "s3",
+		Config: map[string]interface{}{
+			"bucket":  "my-terraform-state",
+			"key":     "infra/network/network.tfstate",
+			"encrypt": true,
+		},
+	}
+
+	var tests = []struct {
+		description string
+		other       *tfconfig.Backend
+		expected    bool
+	}{
+		{
+			"same state",
+			&tfconfig.Backend{Type: "s3", Config: map[string]interface{}{
+				"bucket": "my-terraform-state",
+				"key":    "infra/network/network.tfstate",
+			}},
+			true,
+		},
+		{
+			"different key",
+			&tfconfig.Backend{Type: "s3", Config: map[string]interface{}{
+				"bucket": "my-terraform-state",
+				"key":    "infra/app/app.tfstate",
+			}},
+			false,
+		},
+		{
+			"no key",
+			&tfconfig.Backend{Type: "s3", Config: map[string]interface{}{
+				"bucket": "my-terraform-state",
+			}},
+			false,
+		},
+		{
+			"different workspace key prefix",
+			&tfconfig.Backend{Type: "s3", Config: map[string]interface{}{
+				"bucket":               "my-terraform-state",
+				"key":                  "infra/network/network.tfstate",
+				"workspace_key_prefix": "envs",
+			}},
+			false,
+		},
+		{
+			"different type",
+			&tfconfig.Backend{Type: "gcs", Config: map[string]interface{}{
+				"bucket": "my-terraform-state",
+			}},
+			false,
+		},
+		{
+			"nothing in common",
+			&tfconfig.Backend{Type: "s3", Config: map[string]interface{}{}},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			assert.Equal(t, tt.expected, backend.Matches(tt.other))
+		})
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+
+	return dir
+}
+
+func TestLoadDirModuleCalls(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.tf": `
+provider "aws" {
+  region = "eu-west-1"
+}
+
+module "vpc" {
+  source = "../modules/vpc"
+}
+
+module "label" {
+  source  = "cloudposse/label/null"
+  version = "0.25.0"
+}
+`,
+	})
+
+	m, err := tfconfig.LoadDir(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"aws"}, m.ProviderConfigs)
+	require.Len(t, m.ModuleCalls, 2)
+	assert.Equal(t, "vpc", m.ModuleCalls[0].Name)
+	assert.Equal(t, "../modules/vpc", m.ModuleCalls[0].Source)
+	assert.Equal(t, "", m.ModuleCalls[0].Version)
+	assert.Equal(t, 6, m.ModuleCalls[0].DeclRange.Start.Line)
+	assert.Equal(t, "label", m.ModuleCalls[1].Name)
+	assert.Equal(t, "cloudposse/label/null", m.ModuleCalls[1].Source)
+	assert.Equal(t, "0.25.0", m.ModuleCalls[1].Version)
+}
+
+func TestParseRegistrySource(t *testing.T) {
+	var tests = []struct {
+		source   string
+		expected *tfconfig.RegistrySource
+	}{
+		{"cloudposse/label/null", &tfconfig.RegistrySource{Namespace: "cloudposse", Name: "label", Provider: "null"}},
+		{"terraform-aws-modules/security-group/aws//modules/http-80", &tfconfig.RegistrySource{Namespace: "terraform-aws-modules", Name: "security-group", Provider: "aws", Subdir: "modules/http-80"}},
+		{"app.terraform.io/example-corp/k8s-cluster/azurerm", &tfconfig.RegistrySource{Host: "app.terraform.io", Namespace: "example-corp", Name: "k8s-cluster", Provider: "azurerm"}},
+		{"github.com/hashicorp/example", nil},
+		{"git::https://example.com/vpc.git", nil},
+		{"../modules/vpc", nil},
+		{"//modules/vpc:vpc", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			source, ok := tfconfig.ParseRegistrySource(tt.source)
+			assert.Equal(t, tt.expected != nil, ok)
+			assert.Equal(t, tt.expected, source)
+		})
+	}
+}
+
+func TestLoadDirVariablesAndOutputs(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"versions.tf": `
+terraform {
+  required_providers {
+    null = {
+      source  = "hashicorp/null"
+      version = ">= 3.0"
+    }
+    aws = "~> 4.0"
+  }
+}
+`,
+		"variables.tf": `
+variable "name" {
+  description = "The name of the resources."
+  type        = string
+}
+
+variable "tags" {
+  type = map(object({
+    value = string
+    owner = optional(string)
+  }))
+  default = {}
+}
+
+variable "password" {
+  sensitive = true
+  default   = null
+}
+`,
+		"outputs.tf.json": `{
+  "variable": {
+    "count": {"type": "number", "default": 1}
+  },
+  "output": {
+    "id": {"description": "The ID.", "value": "${null_resource.this.id}", "sensitive": true}
+  }
+}`,
+	})
+
+	m, err := tfconfig.LoadDir(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*tfconfig.RequiredProvider{
+		{Name: "aws", Version: "~> 4.0"},
+		{Name: "null", Source: "hashicorp/null", Version: ">= 3.0"},
+	}, m.RequiredProviders)
+
+	require.Len(t, m.Variables, 4)
+	variables := map[string]*tfconfig.Variable{}
+	for _, v := range m.Variables {
+		v.DeclRange = hcl.Range{}
+		variables[v.Name] = v
+	}
+	assert.Equal(t, &tfconfig.Variable{Name: "count", Type: "number", Default: float64(1)}, variables["count"])
+	assert.Equal(t, &tfconfig.Variable{Name: "name", Description: "The name of the resources.", Type: "string", Required: true}, variables["name"])
+	assert.Equal(t, &tfconfig.Variable{Name: "tags", Type: "map(object({\n    value = string\n    owner = optional(string)\n  }))", Default: map[string]interface{}{}}, variables["tags"])
+	assert.Equal(t, &tfconfig.Variable{Name: "password", Sensitive: true}, variables["password"])
+
+	require.Len(t, m.Outputs, 1)
+	assert.Equal(t, "id", m.Outputs[0].Name)
+	assert.Equal(t, "The ID.", m.Outputs[0].Description)
+	assert.True(t, m.Outputs[0].Sensitive)
+}
+
+func TestLoadVarFile(t *testing.T) {
+	assignments, err := tfconfig.LoadVarFile("vars.tfvars", []byte(`
+replicas = 2
+name     = "network"
+subnets  = ["a", "b"]
+owner    = null
+`))
+	require.NoError(t, err)
+	require.Len(t, assignments, 4)
+	assert.Equal(t, "replicas", assignments[0].Name)
+	assert.Equal(t, float64(2), assignments[0].Value)
+	assert.Equal(t, "name", assignments[1].Name)
+	assert.Equal(t, "network", assignments[1].Value)
+	assert.Equal(t, []interface{}{"a", "b"}, assignments[2].Value)
+	assert.Equal(t, "owner", assignments[3].Name)
+	assert.Nil(t, assignments[3].Value)
+
+	assignments, err = tfconfig.LoadVarFile("vars.tfvars.json", []byte(`{"tags": {"team": "network"}}`))
+	require.NoError(t, err)
+	require.Len(t, assignments, 1)
+	assert.Equal(t, map[string]interface{}{"team": "network"}, assignments[0].Value)
+
+	_, err = tfconfig.LoadVarFile("vars.tfvars", []byte(`name = var.other`))
+	assert.Error(t, err)
+}
+
+func TestCheckVarAssignments(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"variables.tf": `
+variable "name" {
+  type = string
+
+  validation {
+    condition     = length(var.name) <= 8 && can(regex("^[a-z]+$", var.name))
+    error_message = "The name must be at most 8 lowercase letters."
+  }
+}
+
+variable "replicas" {
+  type    = number
+  default = 1
+}
+
+variable "tags" {
+  type = map(object({
+    value = string
+    owner = optional(string, "platform")
+  }))
+  default = {}
+}
+
+variable "cidr" {
+  type = string
+
+  validation {
+    condition     = can(cidrhost(var.cidr, 0))
+    error_message = "The CIDR must be valid."
+  }
+}
+`,
+	})
+	m, err := tfconfig.LoadDir(dir)
+	require.NoError(t, err)
+
+	var tests = []struct {
+		description string
+		varFile     string
+		expected    []string
+	}{
+		{
+			description: "valid",
+			varFile: `
+name     = "network"
+replicas = "3"
+tags     = { env = { value = "prod" } }
+cidr     = "not validated"
+`,
+		},
+		{
+			description: "undeclared variable",
+			varFile:     `region = "eu-west-1"`,
+			expected:    []string{`vars.tfvars:1,1-21: Value for undeclared variable; The root does not declare a variable named "region".`},
+		},
+		{
+			description: "type mismatch",
+			varFile:     "replicas = \"many\"\ntags     = { env = { owner = \"me\" } }\n",
+			expected: []string{
+				`vars.tfvars:1,1-18: Invalid value for variable; The value for variable "replicas" does not conform to its type constraint number: a number is required.`,
+				`vars.tfvars:2,1-38: Invalid value for variable; The value for variable "tags" does not conform to its type constraint map(object({
+    value = string
+    owner = optional(string, "platform")
+  })): element "env": attribute "value" is required.`,
+			},
+		},
+		{
+			description: "failed validation",
+			varFile:     `name = "Network"`,
+			expected:    []string{`vars.tfvars:1,1-17: Invalid value for variable; The value for variable "name" fails the validation at ` + filepath.Join(dir, "variables.tf") + `:5,3-13. The name must be at most 8 lowercase letters.`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			assignments, err := tfconfig.LoadVarFile("vars.tfvars", []byte(tt.varFile))
+			require.NoError(t, err)
+
+			diags := tfconfig.CheckVarAssignments(m.Variables, assignments)
+			actual := []string{}
+			for _, diag := range diags {
+				actual = append(actual, diag.Error())
+			}
+			assert.ElementsMatch(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestCheckRequiredVariables(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"variables.tf": `
+variable "name" {}
+
+variable "region" {
+  default = "eu-west-1"
+}
+
+variable "owner" {}
+`,
+	})
+	m, err := tfconfig.LoadDir(dir)
+	require.NoError(t, err)
+
+	assignments, err := tfconfig.LoadVarFile("vars.tfvars", []byte("name = \"network\"\nowner = null\n"))
+	require.NoError(t, err)
+
+	diags := tfconfig.CheckRequiredVariables(m.Variables, assignments)
+	require.Len(t, diags, 1)
+	assert.Equal(t, filepath.Join(dir, "variables.tf")+`:8,1-17: No value for required variable; The root requires a value for variable "owner", which has no default and is not given by a var file.`, diags[0].Error())
+}
+
+func TestLoadDirBackendUnresolved(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"backend.tf": `
+terraform {
+  backend "s3" {
+    bucket = "my-terraform-state"
+    key    = "${var.env}/network.tfstate"
+  }
+}
+`,
+	})
+
+	m, err := tfconfig.LoadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"bucket": "my-terraform-state"}, m.Backend.Config)
+	assert.Equal(t, []string{"key"}, m.Backend.Unresolved)
+	assert.Equal(t, []string{"key"}, m.Backend.MissingConfig())
+}
@@ -0,0 +1,64 @@
+package tfconfig
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// VarAssignment represents the value which a var file gives a variable.
+type VarAssignment struct {
+	Name  string
+	Value interface{}
+
+	Range hcl.Range
+
+	value cty.Value
+}
+
+// LoadVarFile parses the given contents of the var file with the given name,
+// returning its assignments in the order in which they are written. Files
+// whose names end in `.json` are parsed as JSON.
+func LoadVarFile(filename string, src []byte) ([]*VarAssignment, error) {
+	parser := hclparse.NewParser()
+	var (
+		file  *hcl.File
+		diags hcl.Diagnostics
+	)
+	if strings.HasSuffix(filename, ".json") {
+		file, diags = parser.ParseJSON(src, filename)
+	} else {
+		file, diags = parser.ParseHCL(src, filename)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	attrs, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	assignments := []*VarAssignment{}
+	for name, attr := range attrs {
+		val, valDiags := attr.Expr.Value(nil)
+		if valDiags.HasErrors() {
+			diags = append(diags, valDiags...)
+			continue
+		}
+		a := &VarAssignment{Name: name, Range: attr.Range, value: val}
+		if !val.IsNull() {
+			a.Value, _ = ctyToGo(val)
+		}
+		assignments = append(assignments, a)
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	sort.Slice(assignments, func(i, j int) bool { return assignments[i].Range.Start.Byte < assignments[j].Range.Start.Byte })
+
+	return assignments, nil
+}
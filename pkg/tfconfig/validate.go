@@ -0,0 +1,250 @@
+package tfconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// validationFunctions are the Terraform functions which validation conditions
+// may call to be evaluated statically. Conditions which call any other
+// function are not evaluated.
+var validationFunctions = map[string]function.Function{
+	"abs":        stdlib.AbsoluteFunc,
+	"can":        tryfunc.CanFunc,
+	"ceil":       stdlib.CeilFunc,
+	"contains":   stdlib.ContainsFunc,
+	"endswith":   endsWithFunc,
+	"floor":      stdlib.FloorFunc,
+	"join":       stdlib.JoinFunc,
+	"keys":       stdlib.KeysFunc,
+	"length":     lengthFunc,
+	"lookup":     stdlib.LookupFunc,
+	"lower":      stdlib.LowerFunc,
+	"max":        stdlib.MaxFunc,
+	"min":        stdlib.MinFunc,
+	"regex":      stdlib.RegexFunc,
+	"regexall":   stdlib.RegexAllFunc,
+	"split":      stdlib.SplitFunc,
+	"startswith": startsWithFunc,
+	"substr":     stdlib.SubstrFunc,
+	"trimspace":  stdlib.TrimSpaceFunc,
+	"try":        tryfunc.TryFunc,
+	"upper":      stdlib.UpperFunc,
+	"values":     stdlib.ValuesFunc,
+}
+
+// lengthFunc is Terraform's `length`, which also counts the characters of
+// strings.
+var lengthFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "value", Type: cty.DynamicPseudoType, AllowDynamicType: true, AllowUnknown: true},
+	},
+	Type: function.StaticReturnType(cty.Number),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if args[0].Type() == cty.String {
+			return stdlib.Strlen(args[0])
+		}
+		return stdlib.Length(args[0])
+	},
+})
+
+var startsWithFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "str", Type: cty.String},
+		{Name: "prefix", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.Bool),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		return cty.BoolVal(strings.HasPrefix(args[0].AsString(), args[1].AsString())), nil
+	},
+})
+
+var endsWithFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "str", Type: cty.String},
+		{Name: "suffix", Type: cty.String},
+	},
+	Type: function.StaticReturnType(cty.Bool),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		return cty.BoolVal(strings.HasSuffix(args[0].AsString(), args[1].AsString())), nil
+	},
+})
+
+// CheckVarAssignments returns diagnostics for the given assignments which
+// Terraform would warn about or reject: values for undeclared variables,
+// values which do not conform to the type constraint of their variable and
+// values which fail the variable's validations, of those which can be
+// evaluated statically.
+func CheckVarAssignments(variables []*Variable, assignments []*VarAssignment) hcl.Diagnostics {
+	declared := map[string]*Variable{}
+	for _, v := range variables {
+		declared[v.Name] = v
+	}
+
+	var diags hcl.Diagnostics
+	for _, a := range assignments {
+		v, ok := declared[a.Name]
+		if !ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagWarning,
+				Summary:  "Value for undeclared variable",
+				Detail:   fmt.Sprintf("The root does not declare a variable named %q.", a.Name),
+				Subject:  a.Range.Ptr(),
+			})
+			continue
+		}
+		// Null values leave the variable unset.
+		if a.value.IsNull() {
+			continue
+		}
+
+		val, err := convertVarValue(v, a.value)
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid value for variable",
+				Detail:   fmt.Sprintf("The value for variable %q does not conform to its type constraint %s: %s.", a.Name, v.Type, err),
+				Subject:  a.Range.Ptr(),
+			})
+			continue
+		}
+
+		for _, validation := range v.Validations {
+			if ok, evaluated := evaluateValidation(v.Name, validation, val); evaluated && !ok {
+				detail := fmt.Sprintf("The value for variable %q fails the validation at %s.", a.Name, validation.DeclRange)
+				if validation.ErrorMessage != "" {
+					detail += " " + validation.ErrorMessage
+				}
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid value for variable",
+					Detail:   detail,
+					Subject:  a.Range.Ptr(),
+				})
+			}
+		}
+	}
+
+	return diags
+}
+
+// CheckRequiredVariables returns diagnostics for the given required
+// variables which none of the given assignments give a value.
+func CheckRequiredVariables(variables []*Variable, assignments []*VarAssignment) hcl.Diagnostics {
+	assigned := map[string]struct{}{}
+	for _, a := range assignments {
+		if !a.value.IsNull() {
+			assigned[a.Name] = struct{}{}
+		}
+	}
+
+	var diags hcl.Diagnostics
+	for _, v := range variables {
+		if _, ok := assigned[v.Name]; ok || !v.Required {
+			continue
+		}
+		diags = append(diags, &hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "No value for required variable",
+			Detail:   fmt.Sprintf("The root requires a value for variable %q, which has no default and is not given by a var file.", v.Name),
+			Subject:  v.DeclRange.Ptr(),
+		})
+	}
+
+	return diags
+}
+
+// SortDiagnostics sorts the given diagnostics by the file and position of
+// their subjects.
+func SortDiagnostics(diags hcl.Diagnostics) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Subject, diags[j].Subject
+		if a == nil || b == nil {
+			return a != nil
+		}
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+}
+
+// convertVarValue returns the given value converted to the type constraint of
+// the given variable, with any optional attribute defaults applied.
+func convertVarValue(v *Variable, val cty.Value) (cty.Value, error) {
+	if v.Type == "" {
+		return val, nil
+	}
+
+	expr, diags := hclsyntax.ParseExpression([]byte(v.Type), v.DeclRange.Filename, v.DeclRange.Start)
+	if diags.HasErrors() {
+		return val, nil
+	}
+	ty, defaults, diags := typeexpr.TypeConstraintWithDefaults(expr)
+	if diags.HasErrors() {
+		// The type constraint is checked by Terraform itself.
+		return val, nil
+	}
+	if defaults != nil {
+		val = defaults.Apply(val)
+	}
+
+	return convert.Convert(val, ty)
+}
+
+// evaluateValidation returns whether the given value of the named variable
+// passes the given validation and whether the validation could be evaluated
+// statically: its condition only refers to the variable and calls
+// validationFunctions.
+func evaluateValidation(name string, validation *VariableValidation, val cty.Value) (bool, bool) {
+	expr, ok := validation.Condition.(hclsyntax.Expression)
+	if !ok {
+		return false, false
+	}
+
+	for _, traversal := range expr.Variables() {
+		if traversal.RootName() != "var" || len(traversal) < 2 {
+			return false, false
+		}
+		if attr, ok := traversal[1].(hcl.TraverseAttr); !ok || attr.Name != name {
+			return false, false
+		}
+	}
+	static := true
+	hclsyntax.VisitAll(expr, func(node hclsyntax.Node) hcl.Diagnostics {
+		if call, ok := node.(*hclsyntax.FunctionCallExpr); ok {
+			if _, ok := validationFunctions[call.Name]; !ok {
+				static = false
+			}
+		}
+		return nil
+	})
+	if !static {
+		return false, false
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{"var": cty.ObjectVal(map[string]cty.Value{name: val})},
+		Functions: validationFunctions,
+	}
+	result, diags := expr.Value(ctx)
+	if diags.HasErrors() || !result.IsWhollyKnown() || result.IsNull() {
+		return false, false
+	}
+	result, err := convert.Convert(result, cty.Bool)
+	if err != nil {
+		return false, false
+	}
+
+	return result.True(), true
+}
@@ -0,0 +1,201 @@
+package tfconfig
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Variable represents a `variable` block.
+type Variable struct {
+	Name        string
+	Description string
+	// Type is the variable's type constraint as written, e.g.
+	// `list(string)`. It is empty if no type constraint is given.
+	Type string
+	// Default is the default value of the variable if it is static.
+	Default   interface{}
+	Required  bool
+	Sensitive bool
+	// Validations are the variable's `validation` blocks.
+	Validations []*VariableValidation
+
+	DeclRange hcl.Range
+}
+
+// VariableValidation represents a `validation` block of a variable.
+type VariableValidation struct {
+	Condition hcl.Expression
+	// ErrorMessage is the error message of the validation if it is static.
+	ErrorMessage string
+
+	DeclRange hcl.Range
+}
+
+// Output represents an `output` block.
+type Output struct {
+	Name        string
+	Description string
+	Sensitive   bool
+
+	DeclRange hcl.Range
+}
+
+// RequiredProvider represents an entry of a `required_providers` block.
+type RequiredProvider struct {
+	Name    string
+	Source  string
+	Version string
+}
+
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "description"},
+		{Name: "type"},
+		{Name: "default"},
+		{Name: "sensitive"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "validation"},
+	},
+}
+
+var validationSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "condition", Required: true},
+		{Name: "error_message"},
+	},
+}
+
+var outputSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "description"},
+		{Name: "sensitive"},
+	},
+}
+
+func loadVariable(block *hcl.Block, src []byte) (*Variable, hcl.Diagnostics) {
+	v := &Variable{
+		Name:      block.Labels[0],
+		Required:  true,
+		DeclRange: block.DefRange,
+	}
+
+	content, _, diags := block.Body.PartialContent(variableSchema)
+	if attr, ok := content.Attributes["description"]; ok {
+		if val, ok := staticValue(attr.Expr); ok {
+			v.Description = fmt.Sprint(val)
+		}
+	}
+	if attr, ok := content.Attributes["type"]; ok {
+		v.Type = typeSource(attr.Expr, src)
+	}
+	if attr, ok := content.Attributes["default"]; ok {
+		// A variable with a default, even a null one, is optional.
+		v.Required = false
+		v.Default, _ = staticValue(attr.Expr)
+	}
+	if attr, ok := content.Attributes["sensitive"]; ok {
+		if val, ok := staticValue(attr.Expr); ok {
+			v.Sensitive = val == true
+		}
+	}
+	for _, block := range content.Blocks {
+		validation, validationDiags := loadVariableValidation(block)
+		diags = append(diags, validationDiags...)
+		if validation != nil {
+			v.Validations = append(v.Validations, validation)
+		}
+	}
+
+	return v, diags
+}
+
+func loadVariableValidation(block *hcl.Block) (*VariableValidation, hcl.Diagnostics) {
+	content, _, diags := block.Body.PartialContent(validationSchema)
+	attr, ok := content.Attributes["condition"]
+	if !ok {
+		return nil, diags
+	}
+
+	validation := &VariableValidation{Condition: attr.Expr, DeclRange: block.DefRange}
+	if attr, ok := content.Attributes["error_message"]; ok {
+		if val, ok := staticValue(attr.Expr); ok {
+			validation.ErrorMessage = fmt.Sprint(val)
+		}
+	}
+
+	return validation, diags
+}
+
+func loadOutput(block *hcl.Block) (*Output, hcl.Diagnostics) {
+	o := &Output{
+		Name:      block.Labels[0],
+		DeclRange: block.DefRange,
+	}
+
+	content, _, diags := block.Body.PartialContent(outputSchema)
+	if attr, ok := content.Attributes["description"]; ok {
+		if val, ok := staticValue(attr.Expr); ok {
+			o.Description = fmt.Sprint(val)
+		}
+	}
+	if attr, ok := content.Attributes["sensitive"]; ok {
+		if val, ok := staticValue(attr.Expr); ok {
+			o.Sensitive = val == true
+		}
+	}
+
+	return o, diags
+}
+
+func loadRequiredProviders(block *hcl.Block) ([]*RequiredProvider, hcl.Diagnostics) {
+	attrs, diags := block.Body.JustAttributes()
+
+	providers := []*RequiredProvider{}
+	for name, attr := range attrs {
+		rp := &RequiredProvider{Name: name}
+		val, ok := staticValue(attr.Expr)
+		switch v := val.(type) {
+		case string:
+			// Terraform 0.12 style `name = "<version>"`.
+			rp.Version = v
+		case map[string]interface{}:
+			if source, ok := v["source"].(string); ok {
+				rp.Source = source
+			}
+			if version, ok := v["version"].(string); ok {
+				rp.Version = version
+			}
+		default:
+			if ok {
+				diags = append(diags, &hcl.Diagnostic{
+					Severity: hcl.DiagWarning,
+					Summary:  "Invalid required provider",
+					Detail:   fmt.Sprintf("Required provider %q must be a string or an object.", name),
+					Subject:  attr.Expr.Range().Ptr(),
+				})
+			}
+		}
+		providers = append(providers, rp)
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+
+	return providers, diags
+}
+
+// typeSource returns the source of the given `type` expression. In JSON
+// files, type constraints are strings containing the native syntax.
+func typeSource(expr hcl.Expression, src []byte) string {
+	if _, ok := expr.(hclsyntax.Expression); ok {
+		return string(expr.Range().SliceBytes(src))
+	}
+
+	if val, ok := staticValue(expr); ok {
+		return fmt.Sprint(val)
+	}
+
+	return ""
+}
@@ -0,0 +1,306 @@
+// Package tftest is a harness for testing Terraform modules and roots built
+// with Please from Go tests, in the style of Terratest.
+//
+// A test creates a Terraform working directory from the output of a
+// `terraform_module` or `terraform_root` rule, which it can then init, plan
+// and apply. Everything applied is destroyed when the test finishes.
+//
+//	func TestMyModule(t *testing.T) {
+//		tf := tftest.New(t, &tftest.Options{
+//			TerraformBinary: "third_party/terraform/_1.5_download/terraform",
+//			Dir:             "my_module",
+//			Vars:            map[string]interface{}{"name": "test"},
+//		})
+//		outputs := tf.InitAndApply()
+//		assert.Equal(t, "test", outputs["name"])
+//	}
+package tftest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/plan"
+	"github.com/VJftw/please-terraform/pkg/root"
+)
+
+// varsFileName is the name of the var file which Options.Vars are written to.
+// It sorts after the var files of `terraform_root`s so that it takes
+// precedence over them.
+const varsFileName = "zz-tftest.auto.tfvars.json"
+
+// planFileName is the name of the plan file saved by Plan and applied by Apply.
+const planFileName = "tftest.tfplan"
+
+// Options represents the options for testing a Terraform module or root.
+type Options struct {
+	// TerraformBinary is the path to, or name of, the Terraform binary.
+	TerraformBinary string
+	// Dir is the output directory of a built `terraform_module` or
+	// `terraform_root`.
+	Dir string
+	// Vars are the Terraform variables to set.
+	Vars map[string]interface{}
+	// VarFiles are additional var files to pass to plan and apply.
+	VarFiles []string
+	// Env are additional environment variables in the form `KEY=value`.
+	Env []string
+}
+
+// Terraform represents a Terraform working directory for a test.
+type Terraform struct {
+	// Dir is the temporary working directory Terraform is run in.
+	Dir string
+
+	t               testing.TB
+	opts            *Options
+	terraformBinary string
+	initialised     bool
+	// planned is whether Plan saved a plan which has not yet been applied.
+	planned bool
+}
+
+// New returns a Terraform working directory for the given test, copied from
+// the configured module or root in the same way as a `terraform_root`'s
+// virtual environment. The working directory is destroyed when the test
+// finishes.
+func New(t testing.TB, opts *Options) *Terraform {
+	t.Helper()
+	tf, err := NewE(t, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tf
+}
+
+// NewE is New but returns an error instead of failing the test.
+func NewE(t testing.TB, opts *Options) (*Terraform, error) {
+	terraformBinary := opts.TerraformBinary
+	if terraformBinary == "" {
+		terraformBinary = "terraform"
+	}
+	if strings.ContainsRune(terraformBinary, filepath.Separator) {
+		absBinary, err := filepath.Abs(terraformBinary)
+		if err != nil {
+			return nil, err
+		}
+		terraformBinary = absBinary
+	}
+
+	tf := &Terraform{
+		Dir:             t.TempDir(),
+		t:               t,
+		opts:            opts,
+		terraformBinary: terraformBinary,
+	}
+
+	if err := root.SyncVirtualEnv(opts.Dir, tf.Dir); err != nil {
+		return nil, err
+	}
+
+	if len(opts.Vars) > 0 {
+		varsBytes, err := json.Marshal(opts.Vars)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal vars: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(tf.Dir, varsFileName), varsBytes, 0600); err != nil {
+			return nil, fmt.Errorf("could not write vars: %w", err)
+		}
+	}
+
+	// Registered before t.TempDir's cleanup is run, so that Terraform can
+	// destroy what was applied before its state is removed.
+	t.Cleanup(func() {
+		if !tf.initialised {
+			return
+		}
+		if err := tf.DestroyE(); err != nil {
+			t.Errorf("could not destroy: %s", err)
+		}
+	})
+
+	return tf, nil
+}
+
+// Init runs `terraform init`.
+func (tf *Terraform) Init() {
+	tf.t.Helper()
+	if err := tf.InitE(); err != nil {
+		tf.t.Fatal(err)
+	}
+}
+
+// InitE is Init but returns an error instead of failing the test.
+func (tf *Terraform) InitE() error {
+	if _, err := tf.run("init", "-input=false"); err != nil {
+		return err
+	}
+	tf.initialised = true
+
+	return nil
+}
+
+// Plan runs `terraform plan`, saving the plan for Apply, and returns it.
+func (tf *Terraform) Plan() *plan.Plan {
+	tf.t.Helper()
+	p, err := tf.PlanE()
+	if err != nil {
+		tf.t.Fatal(err)
+	}
+
+	return p
+}
+
+// PlanE is Plan but returns an error instead of failing the test.
+func (tf *Terraform) PlanE() (*plan.Plan, error) {
+	args := append([]string{"plan", "-input=false", "-out=" + planFileName}, tf.varFileArgs()...)
+	if _, err := tf.run(args...); err != nil {
+		return nil, err
+	}
+	tf.planned = true
+
+	planJSON, err := tf.run("show", "-json", planFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.Parse(planJSON)
+}
+
+// Apply runs `terraform apply` and returns the outputs. The plan saved by the
+// last Plan is applied, if it has not been applied already, otherwise the
+// current var files are applied.
+func (tf *Terraform) Apply() map[string]interface{} {
+	tf.t.Helper()
+	outputs, err := tf.ApplyE()
+	if err != nil {
+		tf.t.Fatal(err)
+	}
+
+	return outputs
+}
+
+// ApplyE is Apply but returns an error instead of failing the test.
+func (tf *Terraform) ApplyE() (map[string]interface{}, error) {
+	args := []string{"apply", "-input=false", "-auto-approve"}
+	if tf.planned {
+		args = append(args, planFileName)
+	} else {
+		args = append(args, tf.varFileArgs()...)
+	}
+	_, err := tf.run(args...)
+	if tf.planned {
+		// A saved plan is stale once it has been applied, even in part.
+		tf.planned = false
+		if err := os.Remove(filepath.Join(tf.Dir, planFileName)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("could not remove plan: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return tf.OutputsE()
+}
+
+// InitAndApply runs Init and Apply, returning the outputs.
+func (tf *Terraform) InitAndApply() map[string]interface{} {
+	tf.t.Helper()
+	tf.Init()
+
+	return tf.Apply()
+}
+
+// Outputs returns the values of the root module's outputs.
+func (tf *Terraform) Outputs() map[string]interface{} {
+	tf.t.Helper()
+	outputs, err := tf.OutputsE()
+	if err != nil {
+		tf.t.Fatal(err)
+	}
+
+	return outputs
+}
+
+// OutputsE is Outputs but returns an error instead of failing the test.
+func (tf *Terraform) OutputsE() (map[string]interface{}, error) {
+	outputJSON, err := tf.run("output", "-json")
+	if err != nil {
+		return nil, err
+	}
+
+	rawOutputs := map[string]struct {
+		Value interface{} `json:"value"`
+	}{}
+	if err := json.Unmarshal(outputJSON, &rawOutputs); err != nil {
+		return nil, fmt.Errorf("could not unmarshal outputs: %w", err)
+	}
+
+	outputs := map[string]interface{}{}
+	for name, output := range rawOutputs {
+		outputs[name] = output.Value
+	}
+
+	return outputs, nil
+}
+
+// Destroy runs `terraform destroy`. It is run automatically when the test
+// finishes.
+func (tf *Terraform) Destroy() {
+	tf.t.Helper()
+	if err := tf.DestroyE(); err != nil {
+		tf.t.Fatal(err)
+	}
+}
+
+// DestroyE is Destroy but returns an error instead of failing the test.
+func (tf *Terraform) DestroyE() error {
+	args := append([]string{"destroy", "-input=false", "-auto-approve"}, tf.varFileArgs()...)
+	_, err := tf.run(args...)
+
+	return err
+}
+
+func (tf *Terraform) varFileArgs() []string {
+	args := []string{}
+	for _, varFile := range tf.opts.VarFiles {
+		absVarFile, err := filepath.Abs(varFile)
+		if err != nil {
+			absVarFile = varFile
+		}
+		args = append(args, "-var-file="+absVarFile)
+	}
+
+	return args
+}
+
+// run runs Terraform with the given arguments in the working directory,
+// logging its stderr to the test, and returns its stdout.
+func (tf *Terraform) run(args ...string) ([]byte, error) {
+	cmd := exec.Command(tf.terraformBinary, args...)
+	cmd.Dir = tf.Dir
+	cmd.Env = append(os.Environ(), "TF_IN_AUTOMATION=1", "TF_INPUT=0")
+	cmd.Env = append(cmd.Env, tf.opts.Env...)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	tf.t.Logf("running 'terraform %s' in '%s'", strings.Join(args, " "), tf.Dir)
+	err := cmd.Run()
+	if stderr.Len() > 0 {
+		tf.t.Log(stderr.String())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not run 'terraform %s': %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stdout.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
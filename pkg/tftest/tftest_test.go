@@ -0,0 +1,96 @@
+package tftest_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/VJftw/please-terraform/pkg/tftest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTerraform writes a fake Terraform binary which records its arguments to
+// the returned calls file.
+func fakeTerraform(t *testing.T) (string, string) {
+	planJSON, err := filepath.Abs("testdata/plan.json")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	calls := filepath.Join(dir, "calls")
+	terraformBinary := filepath.Join(dir, "terraform")
+	require.NoError(t, os.WriteFile(terraformBinary, []byte(fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %s
+case "$1" in
+  plan) touch tftest.tfplan ;;
+  show) cat %s ;;
+  output) echo '{"name":{"sensitive":false,"type":"string","value":"test"}}' ;;
+esac
+`, calls, planJSON)), 0755))
+
+	return terraformBinary, calls
+}
+
+func TestTerraform(t *testing.T) {
+	terraformBinary, calls := fakeTerraform(t)
+
+	module := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(module, "main.tf"), []byte(`resource "null_resource" "this" {}`), 0644))
+
+	var workingDir string
+	t.Run("apply", func(t *testing.T) {
+		tf := tftest.New(t, &tftest.Options{
+			TerraformBinary: terraformBinary,
+			Dir:             module,
+			Vars:            map[string]interface{}{"name": "test"},
+		})
+		workingDir = tf.Dir
+
+		assert.FileExists(t, filepath.Join(tf.Dir, "main.tf"))
+		vars, err := os.ReadFile(filepath.Join(tf.Dir, "zz-tftest.auto.tfvars.json"))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"name":"test"}`, string(vars))
+
+		tf.Init()
+		p := tf.Plan()
+		require.Len(t, p.ResourceChanges, 1)
+		assert.Equal(t, "null_resource.this", p.ResourceChanges[0].Address)
+		assert.Equal(t, []string{"create"}, p.ResourceChanges[0].Change.Actions)
+
+		outputs := tf.Apply()
+		assert.Equal(t, map[string]interface{}{"name": "test"}, outputs)
+		assert.NoFileExists(t, filepath.Join(tf.Dir, "tftest.tfplan"))
+
+		// The applied plan is not applied again.
+		tf.Apply()
+	})
+
+	assert.NoDirExists(t, workingDir)
+
+	callsBytes, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	assert.Equal(t, `init -input=false
+plan -input=false -out=tftest.tfplan
+show -json tftest.tfplan
+apply -input=false -auto-approve tftest.tfplan
+output -json
+apply -input=false -auto-approve
+output -json
+destroy -input=false -auto-approve
+`, string(callsBytes))
+}
+
+func TestTerraformNotInitialised(t *testing.T) {
+	terraformBinary, calls := fakeTerraform(t)
+
+	t.Run("new", func(t *testing.T) {
+		tftest.New(t, &tftest.Options{
+			TerraformBinary: terraformBinary,
+			Dir:             t.TempDir(),
+		})
+	})
+
+	// nothing can have been applied, so there is nothing to destroy.
+	assert.NoFileExists(t, calls)
+}